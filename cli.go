@@ -0,0 +1,1106 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"opentable-monitor/config"
+	"opentable-monitor/monitor"
+	"opentable-monitor/notifications"
+)
+
+//  Headless subcommands
+
+// cmdSearch prints every autocomplete hit for the term as a JSON array.
+func cmdSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	term := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if term == "" {
+		return fmt.Errorf("missing search term")
+	}
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results, err := cli.Autocomplete(ctx, term)
+	if err != nil {
+		return err
+	}
+	if results == nil {
+		results = []monitor.AutoResult{}
+	}
+	return printJSON(results)
+}
+
+// cmdAvailability prints the open slots for one or more restaurants over
+// a date window, keyed by restaurant ID and date.
+func cmdAvailability(args []string) error {
+	fs := flag.NewFlagSet("availability", flag.ContinueOnError)
+	rids := fs.String("rid", "", "restaurant ID(s), comma-separated")
+	date := fs.String("date", time.Now().Format("2006-01-02"), "first date (YYYY-MM-DD)")
+	days := fs.Int("days", 0, "extra days after --date to include")
+	timePref := fs.String("time", "19:00", "preferred time (HH:MM, 24-hour)")
+	party := fs.Int("party", 2, "party size")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ids, err := parseIDs(*rids)
+	if err != nil {
+		return err
+	}
+	if err := validateTime(*timePref); err != nil {
+		return err
+	}
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	avail, err := cli.FetchAvailability(ctx, monitor.AvailabilityQuery{
+		RestaurantIDs: ids,
+		Date:          *date,
+		ForwardDays:   *days,
+		Time:          *timePref,
+		PartySize:     *party,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(avail)
+}
+
+// watchEvent is one JSON line written by cmdWatch.
+type watchEvent struct {
+	At           time.Time `json:"at"`
+	Event        string    `json:"event"`
+	WatchID      string    `json:"watchId"`
+	RestaurantID string    `json:"restaurantId"`
+	Date         string    `json:"date"`
+	Time         string    `json:"time,omitempty"`
+	PartySize    int       `json:"partySize"`
+	URL          string    `json:"url,omitempty"`
+	Alternatives []string  `json:"alternatives,omitempty"`
+	Confirmation string    `json:"confirmation,omitempty"` // reservation_booked only
+	DryRun       bool      `json:"dryRun,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// cmdWatch monitors every rid × date combination until each one finds its
+// slot (or Ctrl-C / SIGTERM), writing one JSON event per line to stdout.
+// Alerts also go to the notifiers configured in the environment.
+func cmdWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	rids := fs.String("rid", "", "restaurant ID(s), comma-separated")
+	dates := fs.String("date", "", "date(s) (YYYY-MM-DD), comma-separated")
+	dateRange := fs.String("range", "", `dates to cover instead of --date, e.g. "fri,sat next 6w" or 2025-11-01..2025-11-15`)
+	timePref := fs.String("time", "19:00", "preferred time (HH:MM, 24-hour)")
+	times := fs.String("times", "", "ranked preferred times, comma-separated (best first)")
+	tolerance := fs.Duration("tolerance", 0, "accept slots this far either side of a preferred time, e.g. 15m")
+	window := fs.String("window", "", "accept any slot in this range, e.g. 18:30-20:30")
+	policy := fs.String("policy", "stop", "after a match: stop, or continue looking for a better one")
+	include := fs.String("include", "", "only slots with one of these attributes, comma-separated (e.g. default,outdoor)")
+	exclude := fs.String("exclude", "", "skip slots with any of these attributes, comma-separated (e.g. bar,highTop)")
+	inventory := fs.String("inventory", "", "standard or experience (default: both)")
+	party := fs.Int("party", 2, "party size")
+	partyMax := fs.Int("party-max", 0, "also accept slots for parties up to this size")
+	jitter := fs.Duration("jitter", 0, "randomly move each poll by up to this much, e.g. 10s")
+	fast := fs.String("fast", "", "poll faster in these daily windows, comma-separated, e.g. 10:00+15m@5s")
+	quiet := fs.String("quiet", "", "poll every 10m during these hours, e.g. 01:00-07:00")
+	farDays := fs.Int("far-days", 0, "poll every 5m for dates more than this many days out")
+	tz := fs.String("tz", "", "time zone of --fast and --quiet, e.g. America/Toronto (default: local)")
+	autobook := fs.Bool("autobook", false, "reserve the best match for the diner in DINER_* env vars")
+	dryRun := fs.Bool("dry-run", false, "with --autobook, report what would be booked without booking")
+	alertAfter := fs.Int("alert-after", 0, "failed polls in a row before a watch_degraded alert (default 5)")
+	giveUpAfter := fs.Int("give-up-after", 0, "failed polls in a row before a watch stops (default: never)")
+	state := fs.String("state", defaultStateFile, `state file for resuming after a restart ("" = off)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ids, err := parseIDs(*rids)
+	if err != nil {
+		return err
+	}
+	if err := validateTime(*timePref); err != nil {
+		return err
+	}
+	match, err := flagMatch(*times, *tolerance, *window)
+	if err != nil {
+		return err
+	}
+	schedule := monitor.Schedule{Jitter: *jitter, Quiet: *quiet, FarDays: *farDays, TZ: *tz}
+	for _, f := range splitList(*fast) {
+		fw, err := monitor.ParseFastWindow(f)
+		if err != nil {
+			return err
+		}
+		schedule.Fast = append(schedule.Fast, fw)
+	}
+	dateList := splitList(*dates)
+	var spec monitor.DateSpec
+	if *dateRange != "" {
+		if spec, err = monitor.ParseDateSpec(*dateRange); err != nil {
+			return fmt.Errorf("bad --range: %w", err)
+		}
+	} else if len(dateList) == 0 {
+		return fmt.Errorf("missing --date or --range")
+	}
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	var booker *monitor.Booker
+	if *autobook {
+		if booker, err = cli.NewBooker(envDiner(), *dryRun); err != nil {
+			return fmt.Errorf("--autobook: %w", err)
+		}
+	}
+
+	target := envNotifier()
+
+	r := newRunner(cli)
+	if err := r.useStore(*state); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		rid := strconv.Itoa(id)
+		restaurant := monitor.AutoResult{ID: rid, Name: "Restaurant " + rid}
+		w := monitor.Watch{
+			RestaurantID: rid,
+			TimePref:     *timePref,
+			PartySize:    *party,
+			PartyMax:     *partyMax,
+			Match:        match,
+			Policy:       monitor.MatchPolicy(*policy),
+			Filter: monitor.SlotFilter{
+				Include:   splitList(*include),
+				Exclude:   splitList(*exclude),
+				Inventory: monitor.Inventory(*inventory),
+			},
+			Schedule: schedule,
+			Retry:    monitor.RetryPolicy{AlertAfter: *alertAfter, GiveUpAfter: *giveUpAfter},
+			Booker:   booker,
+		}
+		if *dateRange != "" {
+			w.ID = rid
+			if err := r.addRange(w, spec, restaurant, target); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, d := range dateList {
+			w.ID, w.Date = rid+"-"+d, d
+			if err := r.add(w, restaurant, target); err != nil {
+				return err
+			}
+		}
+	}
+	return r.run()
+}
+
+// flagMatch builds the time-matching rules from the --times, --tolerance
+// and --window flags.
+func flagMatch(times string, tolerance time.Duration, window string) (monitor.TimeMatch, error) {
+	match := monitor.TimeMatch{Preferred: splitList(times), Tolerance: tolerance}
+	if window != "" {
+		from, to, ok := strings.Cut(window, "-")
+		if !ok {
+			return match, fmt.Errorf("bad --window %q (want HH:MM-HH:MM)", window)
+		}
+		match.WindowStart, match.WindowEnd = strings.TrimSpace(from), strings.TrimSpace(to)
+	}
+	return match, nil
+}
+
+// cmdUpgrade watches for a better slot than a booking already held: one
+// of the account's reservations (--id), or one described by hand with
+// --rid, --date, --party and --current. With --automodify the reservation
+// is moved as soon as a better slot can be secured.
+func cmdUpgrade(args []string) error {
+	fs := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	ref := fs.String("id", "", "reservation ID or confirmation number to upgrade (needs login)")
+	rid := fs.String("rid", "", "restaurant ID, when not using --id")
+	date := fs.String("date", "", "date of the booking (YYYY-MM-DD), when not using --id")
+	party := fs.Int("party", 2, "party size, when not using --id")
+	current := fs.String("current", "", "time of the booking held (HH:MM), when not using --id")
+	ideal := fs.String("time", "", "ideal time (HH:MM, 24-hour)")
+	times := fs.String("times", "", "ranked preferred times, comma-separated (best first)")
+	tolerance := fs.Duration("tolerance", 0, "accept slots this far either side of a preferred time, e.g. 15m")
+	window := fs.String("window", "", "accept any slot in this range, e.g. 18:30-20:30")
+	policy := fs.String("policy", "stop", "after a better slot: stop, or continue looking for an even better one")
+	automodify := fs.Bool("automodify", false, "move the reservation (--id) to the better slot automatically")
+	dryRun := fs.Bool("dry-run", false, "with --automodify, report the move without making it")
+	state := fs.String("state", defaultStateFile, `state file for resuming after a restart ("" = off)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validateTime(*ideal); err != nil {
+		return fmt.Errorf("--time: %w", err)
+	}
+	match, err := flagMatch(*times, *tolerance, *window)
+	if err != nil {
+		return err
+	}
+	if *automodify && *ref == "" {
+		return fmt.Errorf("--automodify needs --id: only a reservation on your account can be moved")
+	}
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	w := monitor.Watch{
+		TimePref: *ideal,
+		Match:    match,
+		Policy:   monitor.MatchPolicy(*policy),
+	}
+	var restaurant monitor.AutoResult
+	if *ref != "" {
+		res, err := findReservation(cli, *ref)
+		if err != nil {
+			return err
+		}
+		w.ID = "upgrade-" + res.ConfirmationNumber
+		w.RestaurantID, w.Date, w.PartySize = strconv.Itoa(res.RestaurantID), res.Date, res.PartySize
+		w.Upgrade = &monitor.Upgrade{Current: res.Time, Reservation: res}
+		restaurant = monitor.AutoResult{ID: w.RestaurantID, Name: cmp.Or(res.RestaurantName, "Restaurant "+w.RestaurantID)}
+	} else {
+		if *rid == "" || *date == "" || *current == "" {
+			return fmt.Errorf("pass --id, or --rid, --date and --current")
+		}
+		if err := validateTime(*current); err != nil {
+			return fmt.Errorf("--current: %w", err)
+		}
+		w.ID = "upgrade-" + *rid + "-" + *date
+		w.RestaurantID, w.Date, w.PartySize = *rid, *date, *party
+		w.Upgrade = &monitor.Upgrade{Current: *current}
+		restaurant = monitor.AutoResult{ID: *rid, Name: "Restaurant " + *rid}
+	}
+	if *automodify {
+		if w.Booker, err = cli.NewBooker(monitor.Diner{}, *dryRun); err != nil {
+			return fmt.Errorf("--automodify: %w", err)
+		}
+	}
+
+	r := newRunner(cli)
+	if err := r.useStore(*state); err != nil {
+		return err
+	}
+	if err := r.add(w, restaurant, envNotifier()); err != nil {
+		return err
+	}
+	return r.run()
+}
+
+// cmdSnipe waits for the moment a restaurant releases --date, warms the
+// session up shortly before it and then polls in a tight burst, so a
+// match is announced (or booked with --autobook) within seconds of the
+// drop. The release is --release-at on the day --days-ahead before
+// --date, or the explicit --release timestamp.
+func cmdSnipe(args []string) error {
+	fs := flag.NewFlagSet("snipe", flag.ContinueOnError)
+	rid := fs.String("rid", "", "restaurant ID")
+	date := fs.String("date", "", "date to book (YYYY-MM-DD)")
+	timePref := fs.String("time", "19:00", "preferred time (HH:MM, 24-hour)")
+	times := fs.String("times", "", "ranked preferred times, comma-separated (best first)")
+	tolerance := fs.Duration("tolerance", 0, "accept slots this far either side of a preferred time, e.g. 15m")
+	window := fs.String("window", "", "accept any slot in this range, e.g. 18:30-20:30")
+	party := fs.Int("party", 2, "party size")
+	releaseAt := fs.String("release-at", "", "time of day the restaurant releases bookings (HH:MM)")
+	daysAhead := fs.Int("days-ahead", 0, "how many days ahead the restaurant releases bookings")
+	release := fs.String("release", "", "exact release moment instead, RFC 3339, e.g. 2025-10-01T10:00:00-04:00")
+	tz := fs.String("tz", "", "time zone of --release-at, e.g. America/New_York (default: local)")
+	warmup := fs.Duration("warmup", 0, "refresh the session this long before the burst (default 1m)")
+	before := fs.Duration("before", 0, "start bursting this long before the release (default 5s)")
+	after := fs.Duration("after", 0, "keep bursting this long after the release (default 2m)")
+	every := fs.Duration("every", 0, "poll interval during the burst (default 750ms)")
+	follow := fs.Bool("follow", false, "keep watching at the normal interval if the burst finds nothing")
+	autobook := fs.Bool("autobook", false, "reserve the best match for the diner in DINER_* env vars")
+	dryRun := fs.Bool("dry-run", false, "with --autobook, report what would be booked without booking")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *rid == "" || *date == "" {
+		return fmt.Errorf("missing --rid or --date")
+	}
+	if err := validateTime(*timePref); err != nil {
+		return err
+	}
+	match, err := flagMatch(*times, *tolerance, *window)
+	if err != nil {
+		return err
+	}
+	var at time.Time
+	switch {
+	case *release != "":
+		if at, err = time.Parse(time.RFC3339, *release); err != nil {
+			return fmt.Errorf("bad --release: %w", err)
+		}
+	case *releaseAt != "":
+		if at, err = monitor.ReleaseTime(*date, *daysAhead, *releaseAt, *tz); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("missing --release-at (with --days-ahead) or --release")
+	}
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+
+	w := monitor.Watch{
+		ID:           "snipe-" + *rid + "-" + *date,
+		RestaurantID: *rid,
+		Date:         *date,
+		TimePref:     *timePref,
+		PartySize:    *party,
+		Match:        match,
+		Policy:       monitor.PolicyStop,
+	}
+	if *autobook {
+		if w.Booker, err = cli.NewBooker(envDiner(), *dryRun); err != nil {
+			return fmt.Errorf("--autobook: %w", err)
+		}
+	}
+
+	restaurant := monitor.AutoResult{ID: *rid, Name: "Restaurant " + *rid}
+	target := envNotifier()
+	r := newRunner(cli)
+	r.targets[w.ID] = target
+	w.OnEvent = func(ev monitor.WatchEvent) {
+		r.publish(notifications.FromWatchEvent(ev, restaurant), target)
+	}
+	event := func(typ notifications.EventType, reason string) {
+		r.publish(notifications.Event{
+			Type:       typ,
+			WatchID:    w.ID,
+			Restaurant: restaurant,
+			Date:       w.Date,
+			Time:       w.TimePref,
+			PartySize:  w.PartySize,
+			Reason:     reason,
+		}, target)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	event(notifications.EventStarted, "")
+	err = cli.Snipe(ctx, w, monitor.Snipe{
+		Release: at,
+		Warmup:  *warmup,
+		Before:  *before,
+		After:   *after,
+		Every:   *every,
+		Follow:  *follow,
+	})
+	switch {
+	case err == nil:
+		event(notifications.EventStopped, stopReason(monitor.WatchFound))
+	case errors.Is(err, monitor.ErrSnipeMissed):
+		event(notifications.EventStopped, "Nothing matched during the release burst")
+	case errors.Is(err, context.Canceled):
+		event(notifications.EventStopped, stopReason(monitor.WatchStopped))
+		err = nil // Ctrl-C / SIGTERM is a normal way to stop
+	default:
+		event(notifications.EventStopped, err.Error())
+	}
+	reportProxies(cli)
+	r.flush()
+	return err
+}
+
+// cmdRun loads a watch file and monitors everything it declares.
+func cmdRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	path := fs.String("config", "watches.yaml", "watch file to load")
+	state := fs.String("state", "", `state file (default: "state" in the config, else `+defaultStateFile+`)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		return err
+	}
+
+	pool, err := cfg.Proxies.Pool()
+	if err != nil {
+		return err
+	}
+	cli, err := newClient(pool)
+	if err != nil {
+		return err
+	}
+	for name, hash := range cfg.Queries {
+		if err := monitor.SetQuery(name, hash, ""); err != nil {
+			return err
+		}
+	}
+
+	notifiers := map[string]notifications.Notifier{}
+	for name, n := range cfg.Notifiers {
+		nt, err := buildNotifier(n)
+		if err != nil {
+			return fmt.Errorf("notifier %q: %w", name, err)
+		}
+		notifiers[name] = notifications.NewAsync(nt, logNotifyErr)
+	}
+
+	statePath := *state
+	if statePath == "" {
+		statePath = cfg.State
+	}
+	if statePath == "" {
+		statePath = defaultStateFile
+	}
+
+	var booker, mover *monitor.Booker
+	if slices.ContainsFunc(cfg.Watches, func(cw config.Watch) bool { return cw.AutoBook && cw.Reservation == "" }) {
+		if booker, err = cli.NewBooker(cfg.Booking.Diner(), cfg.Booking.DryRun); err != nil {
+			return fmt.Errorf("booking: %w", err)
+		}
+	}
+	if slices.ContainsFunc(cfg.Watches, func(cw config.Watch) bool { return cw.AutoBook && cw.Reservation != "" }) {
+		if mover, err = cli.NewBooker(monitor.Diner{}, cfg.Booking.DryRun); err != nil {
+			return fmt.Errorf("booking: %w", err)
+		}
+	}
+
+	r := newRunner(cli)
+	if err := r.useStore(statePath); err != nil {
+		return err
+	}
+	r.wl.SetInterval(cfg.Interval)
+	for _, cw := range cfg.Watches {
+		var held *monitor.Reservation
+		if cw.Reservation != "" {
+			if held, err = findReservation(cli, cw.Reservation); err != nil {
+				return fmt.Errorf("%s:%d: watch %q: %w", *path, cw.Line, cw.ID, err)
+			}
+			cw.Restaurant, cw.Dates, cw.Party = strconv.Itoa(held.RestaurantID), []string{held.Date}, held.PartySize
+		}
+		restaurant, err := resolveRestaurant(cli, cw.Restaurant)
+		if err != nil {
+			return fmt.Errorf("%s:%d: watch %q: %w", *path, cw.Line, cw.ID, err)
+		}
+		if held != nil && held.RestaurantName != "" {
+			restaurant.Name = held.RestaurantName
+		}
+
+		var targets notifications.Multi
+		for _, name := range cfg.NotifierNames(cw) {
+			targets = append(targets, notifiers[name])
+		}
+
+		w := cfg.MonitorWatch(cw)
+		w.RestaurantID = restaurant.ID
+		switch {
+		case held != nil:
+			w.Upgrade = &monitor.Upgrade{Current: held.Time, Reservation: held}
+			if cw.AutoBook {
+				w.Booker = mover
+			}
+		case cw.AutoBook && w.Upgrade == nil: // upgrade watches only move a held booking
+			w.Booker = booker
+		}
+		if spec, ok := cw.DateSpec(); ok {
+			if err := r.addRange(w, spec, restaurant, targets); err != nil {
+				return fmt.Errorf("%s:%d: %w", *path, cw.Line, err)
+			}
+			continue
+		}
+		for _, d := range cw.Dates {
+			w.ID, w.Date = cw.ID, d
+			if len(cw.Dates) > 1 {
+				w.ID += "-" + d
+			}
+			if err := r.add(w, restaurant, targets); err != nil {
+				return fmt.Errorf("%s:%d: %w", *path, cw.Line, err)
+			}
+		}
+	}
+	return r.run()
+}
+
+// findReservation looks up one of the signed-in account's reservations.
+func findReservation(cli *monitor.Client, ref string) (*monitor.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := cli.FindReservation(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// cmdLogin signs in, with imported browser cookies or with
+// OPENTABLE_EMAIL/OPENTABLE_PASSWORD, saves the session for later commands
+// and prints the signed-in user.
+func cmdLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	cookies := fs.String("cookies", "", "cookies exported from a signed-in browser (JSON or cookies.txt)")
+	email := fs.String("email", os.Getenv("OPENTABLE_EMAIL"), "account email (password from OPENTABLE_PASSWORD)")
+	session := fs.String("session", sessionPath(), "file to keep the session in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	creds := monitor.Credentials{Email: *email, Password: os.Getenv("OPENTABLE_PASSWORD")}
+	if *cookies == "" && creds.Password == "" {
+		return fmt.Errorf("pass -cookies, or set OPENTABLE_PASSWORD to sign in with -email")
+	}
+
+	pool, err := envProxies()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cli, err := monitor.New(ctx, pool)
+	if err != nil {
+		return fmt.Errorf("monitor init: %w", err)
+	}
+	if err := cli.UseSession(*session, creds); err != nil {
+		return err
+	}
+	if *cookies != "" {
+		n, err := cli.ImportCookies(*cookies)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(monitor.Output, "🍪  Imported %d cookies\n", n)
+		if err := cli.RefreshSession(ctx); err != nil {
+			return err
+		}
+	} else if err := cli.Login(ctx, creds); err != nil {
+		return err
+	}
+	fmt.Fprintf(monitor.Output, "🔑  Signed in as %s, session saved to %s\n", cli.User(), *session)
+	return printJSON(cli.User())
+}
+
+// cmdReservations prints the signed-in account's upcoming reservations.
+func cmdReservations(args []string) error {
+	fs := flag.NewFlagSet("reservations", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	list, err := cli.Reservations(ctx)
+	if err != nil {
+		return err
+	}
+	return printJSON(list)
+}
+
+// cmdCancel cancels one upcoming reservation.
+func cmdCancel(args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	ref := fs.String("id", "", "reservation ID or confirmation number (see reservations)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *ref == "" {
+		return fmt.Errorf("missing -id")
+	}
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := cli.FindReservation(ctx, *ref)
+	if err != nil {
+		return err
+	}
+	if err := cli.CancelReservation(ctx, res); err != nil {
+		return err
+	}
+	res.Status = "cancelled"
+	return printJSON(res)
+}
+
+// cmdModify moves one upcoming reservation to another slot.
+func cmdModify(args []string) error {
+	fs := flag.NewFlagSet("modify", flag.ContinueOnError)
+	ref := fs.String("id", "", "reservation ID or confirmation number (see reservations)")
+	date := fs.String("date", "", "new date (YYYY-MM-DD); default keeps it")
+	timePref := fs.String("time", "", "new time (HH:MM, 24-hour); default keeps it")
+	party := fs.Int("party", 0, "new party size; default keeps it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *ref == "" {
+		return fmt.Errorf("missing -id")
+	}
+	if *timePref != "" {
+		if err := validateTime(*timePref); err != nil {
+			return err
+		}
+	}
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+	res, err := cli.FindReservation(ctx, *ref)
+	if err != nil {
+		return err
+	}
+	bk, err := cli.ModifyReservation(ctx, res, monitor.ReservationChange{
+		Date:      *date,
+		Time:      *timePref,
+		PartySize: *party,
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(bk)
+}
+
+// cmdQueries prints the GraphQL operations the client sends and the
+// hashes it sends them by, or looks the current hashes up on the site.
+func cmdQueries(args []string) error {
+	fs := flag.NewFlagSet("queries", flag.ContinueOnError)
+	discover := fs.Bool("discover", false, "search the site's scripts for the current hashes")
+	save := fs.Bool("save", false, "with -discover: keep what was found in "+queriesPath()+" (OPENTABLE_QUERIES)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*discover {
+		if err := monitor.LoadQueries(queriesPath()); err != nil {
+			return err
+		}
+		return printJSON(monitor.Queries())
+	}
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	found, err := cli.DiscoverQueries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, op := range monitor.Queries() {
+		f, ok := found[op.Name]
+		switch {
+		case !ok:
+			fmt.Fprintf(monitor.Output, "❔  %s: not found, keeping %s\n", op.Name, op.Hash)
+		case f.Hash != op.Hash:
+			fmt.Fprintf(monitor.Output, "🧩  %s: %s → %s\n", op.Name, op.Hash, f.Hash)
+		}
+		if ok {
+			if err := monitor.SetQuery(f.Name, f.Hash, f.Query); err != nil {
+				return err
+			}
+		}
+	}
+	if *save {
+		if err := monitor.SaveQueries(queriesPath()); err != nil {
+			return err
+		}
+		fmt.Fprintf(monitor.Output, "💾  Saved to %s\n", queriesPath())
+	}
+	return printJSON(monitor.Queries())
+}
+
+// cmdProxies sends one request through every proxy of the pool and
+// prints how each one did.
+func cmdProxies(args []string) error {
+	fs := flag.NewFlagSet("proxies", flag.ContinueOnError)
+	file := fs.String("file", os.Getenv("OPENTABLE_PROXIES"), "proxy list, one per line (default OPENTABLE_PROXIES)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("missing --file (or OPENTABLE_PROXIES)")
+	}
+	pool, err := monitor.LoadProxies(*file, monitor.ProxyMode(os.Getenv("OPENTABLE_PROXY_MODE")))
+	if err != nil {
+		return err
+	}
+
+	cli, err := newClient(pool)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	stats, err := cli.CheckProxies(ctx)
+	if err != nil {
+		return err
+	}
+	return printJSON(stats)
+}
+
+// cmdResume restarts every watch left running in the state file, e.g.
+// after a crash, alerting the notifiers configured in the environment.
+func cmdResume(args []string) error {
+	fs := flag.NewFlagSet("resume", flag.ContinueOnError)
+	state := fs.String("state", defaultStateFile, "state file to resume from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := monitor.NewFileStore(*state)
+	if err != nil {
+		return err
+	}
+	recs, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	target := envNotifier()
+
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+	r := newRunner(cli)
+	if err := r.wl.UseStore(store); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(recs))
+	for id := range recs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// a range watch that matched on one date is finished on all of them
+	doneGroups := map[string]bool{}
+	for _, rec := range recs {
+		if rec.Status == monitor.WatchFound && rec.Watch.Group != "" && rec.Watch.Policy != monitor.PolicyContinue {
+			doneGroups[rec.Watch.Group] = true
+		}
+	}
+
+	resumed := 0
+	for _, id := range ids {
+		rec := recs[id]
+		if rec.Status == monitor.WatchFound || rec.Status == monitor.WatchExpired || doneGroups[rec.Watch.Group] {
+			continue
+		}
+		w := rec.Watch
+		restaurant := monitor.AutoResult{ID: w.RestaurantID, Name: "Restaurant " + w.RestaurantID}
+		if err := r.add(w, restaurant, target); err != nil {
+			return err
+		}
+		resumed++
+	}
+	if resumed == 0 {
+		return fmt.Errorf("no unfinished watches in %s", *state)
+	}
+	return r.run()
+}
+
+// buildNotifier creates the backend a config notifier describes.
+func buildNotifier(n config.Notifier) (notifications.Notifier, error) {
+	switch n.Type {
+	case "discord":
+		d := notifications.NewDiscordNotifier(n.URL)
+		if n.Queue != "" {
+			if _, err := d.EnableQueue(n.Queue); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case "slack":
+		return notifications.NewSlackNotifier(n.URL), nil
+	case "webhook":
+		return notifications.NewWebhookNotifier(n.URL, n.Secret), nil
+	case "email":
+		return notifications.NewEmailNotifier(notifications.EmailConfig{
+			Host:     n.Host,
+			Port:     n.Port,
+			Username: n.Username,
+			Password: n.Password,
+			From:     n.From,
+			To:       n.To,
+			Security: n.Security,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", n.Type)
+	}
+}
+
+// resolveRestaurant turns a configured restaurant (numeric ID or name)
+// into an AutoResult, using autocomplete for names.
+func resolveRestaurant(cli *monitor.Client, ref string) (monitor.AutoResult, error) {
+	ref = strings.TrimSpace(ref)
+	if _, err := strconv.Atoi(ref); err == nil {
+		return monitor.AutoResult{ID: ref, Name: "Restaurant " + ref}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results, err := cli.Autocomplete(ctx, ref)
+	if err != nil {
+		return monitor.AutoResult{}, fmt.Errorf("resolve %q: %w", ref, err)
+	}
+	var fallback *monitor.AutoResult
+	for i, r := range results {
+		if r.Type != "Restaurant" {
+			continue
+		}
+		if strings.EqualFold(r.Name, ref) {
+			return r, nil
+		}
+		if fallback == nil {
+			fallback = &results[i]
+		}
+	}
+	if fallback == nil {
+		return monitor.AutoResult{}, fmt.Errorf("no restaurant matches %q", ref)
+	}
+	return *fallback, nil
+}
+
+//  Watch runner
+
+// runner wires watches to JSON lines on stdout and to their notifier,
+// then drives them on a single WatchList.
+type runner struct {
+	cli         *monitor.Client
+	wl          *monitor.WatchList
+	enc         *json.Encoder
+	restaurants map[string]monitor.AutoResult
+	targets     map[string]notifications.Notifier
+}
+
+// flushTimeout bounds how long exit waits on queued notifications.
+const flushTimeout = 30 * time.Second
+
+// defaultStateFile is where watch state is kept unless told otherwise.
+const defaultStateFile = "opentable-state.json"
+
+func newRunner(cli *monitor.Client) *runner {
+	return &runner{
+		cli:         cli,
+		wl:          cli.NewWatchList(),
+		enc:         json.NewEncoder(os.Stdout),
+		restaurants: map[string]monitor.AutoResult{},
+		targets:     map[string]notifications.Notifier{},
+	}
+}
+
+// useStore persists watch state in the JSON file at path ("" = off).
+func (r *runner) useStore(path string) error {
+	if path == "" {
+		return nil
+	}
+	store, err := monitor.NewFileStore(path)
+	if err != nil {
+		return err
+	}
+	return r.wl.UseStore(store)
+}
+
+// publish writes ev to stdout and hands it to target (nil = stdout only).
+// Targets deliver in the background, so this never waits on a backend.
+func (r *runner) publish(ev notifications.Event, target notifications.Notifier) {
+	out := watchEvent{
+		At:           time.Now().UTC(),
+		Event:        string(ev.Type),
+		WatchID:      ev.WatchID,
+		RestaurantID: ev.Restaurant.ID,
+		Date:         ev.Date,
+		Time:         ev.Time,
+		PartySize:    ev.PartySize,
+		URL:          ev.URL,
+		Alternatives: ev.Alternatives,
+	}
+	if ev.Reason != "" {
+		out.Error = ev.Reason // includes why an auto-book failed
+	}
+	if b := ev.Booking; b != nil {
+		out.Confirmation, out.DryRun = b.ConfirmationNumber, b.DryRun
+	}
+	if err := r.enc.Encode(out); err != nil {
+		log.Printf("write event: %v", err)
+	}
+
+	if target == nil {
+		return
+	}
+	if err := target.Notify(ev); err != nil {
+		logNotifyErr(ev, err)
+	}
+}
+
+// add registers w and announces it on stdout and to target.
+func (r *runner) add(w monitor.Watch, restaurant monitor.AutoResult, target notifications.Notifier) error {
+	w.OnEvent = func(ev monitor.WatchEvent) {
+		r.publish(notifications.FromWatchEvent(ev, restaurant), target)
+	}
+	if err := r.wl.Add(w); err != nil {
+		return err
+	}
+	r.restaurants[w.Origin()] = restaurant
+	r.targets[w.Origin()] = target
+
+	r.publish(notifications.Event{
+		Type:       notifications.EventStarted,
+		WatchID:    w.ID,
+		Restaurant: restaurant,
+		Date:       w.Date,
+		Time:       w.TimePref,
+		PartySize:  w.PartySize,
+	}, target)
+	return nil
+}
+
+// addRange registers w over every date of spec, announcing it once.
+func (r *runner) addRange(w monitor.Watch, spec monitor.DateSpec, restaurant monitor.AutoResult, target notifications.Notifier) error {
+	w.OnEvent = func(ev monitor.WatchEvent) {
+		r.publish(notifications.FromWatchEvent(ev, restaurant), target)
+	}
+	if err := r.wl.AddRange(w, spec); err != nil {
+		return err
+	}
+	r.restaurants[w.ID] = restaurant
+	r.targets[w.ID] = target
+
+	r.publish(notifications.Event{
+		Type:       notifications.EventStarted,
+		WatchID:    w.ID,
+		Restaurant: restaurant,
+		Date:       spec.String(),
+		Time:       w.TimePref,
+		PartySize:  w.PartySize,
+	}, target)
+	return nil
+}
+
+// run blocks until every watch has finished or Ctrl-C / SIGTERM, then
+// reports a stop event per watch.
+func (r *runner) run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runErr := r.wl.Run(ctx)
+
+	for _, st := range r.wl.Watches() {
+		r.publish(notifications.Event{
+			Type:       notifications.EventStopped,
+			WatchID:    st.Watch.ID,
+			Restaurant: r.restaurants[st.Watch.Origin()],
+			Date:       st.Watch.Date,
+			Time:       st.Watch.TimePref,
+			PartySize:  st.Watch.PartySize,
+			Reason:     stopReason(st.Status),
+		}, r.targets[st.Watch.Origin()])
+	}
+	reportProxies(r.cli)
+
+	r.flush()
+
+	if runErr == context.Canceled {
+		return nil // Ctrl-C / SIGTERM is a normal way to stop
+	}
+	return runErr
+}
+
+// flush gives queued notifications a moment to go out before exiting.
+func (r *runner) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	for _, n := range r.targets {
+		if n == nil {
+			continue
+		}
+		if err := notifications.Flush(ctx, n); err != nil {
+			log.Printf("notify: %v", err)
+		}
+	}
+}
+
+//  Flag helpers
+
+// reportProxies prints how each proxy fared, if the client used any.
+func reportProxies(cli *monitor.Client) {
+	for _, s := range cli.ProxyStats() {
+		fmt.Fprintf(monitor.Output, "🌐  %s\n", s)
+	}
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func parseIDs(s string) ([]int, error) {
+	parts := splitList(s)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("missing --rid")
+	}
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("restaurant id %q: %w", p, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func validateTime(v string) error {
+	if _, err := time.Parse("15:04", v); err != nil {
+		return fmt.Errorf("invalid time %q (want HH:MM)", v)
+	}
+	return nil
+}
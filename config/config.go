@@ -0,0 +1,581 @@
+// Package config loads the declarative watch file: which restaurants to
+// monitor, when, for how many people, and where to send alerts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"opentable-monitor/monitor"
+)
+
+// Config is the top-level document of a watch file.
+type Config struct {
+	Interval  time.Duration       `yaml:"interval"`
+	State     string              `yaml:"state"` // state file for resuming
+	Notifiers map[string]Notifier `yaml:"notifiers"`
+	Booking   Booking             `yaml:"booking"` // diner profile for watches with autobook
+	Retry     Retry               `yaml:"retry"`   // how every watch rides out failed polls
+	Queries   map[string]string   `yaml:"queries"` // GraphQL operation name → persisted-query hash override
+	Proxies   Proxies             `yaml:"proxies"` // where requests go out through
+	Watches   []Watch             `yaml:"watches"`
+
+	path         string
+	intervalLine int
+	bookingLine  int
+	retryLine    int
+	queriesLine  int
+	proxiesLine  int
+}
+
+// Booking is the diner profile auto-book reserves under.
+type Booking struct {
+	FirstName string `yaml:"first_name"`
+	LastName  string `yaml:"last_name"`
+	Email     string `yaml:"email"`
+	Phone     string `yaml:"phone"`
+	Country   string `yaml:"country"` // of the phone number, e.g. CA
+	Notes     string `yaml:"notes"`   // special requests
+	DryRun    bool   `yaml:"dry_run"` // go through the motions without booking
+}
+
+// Diner returns the profile in the form the monitor package books with.
+func (b Booking) Diner() monitor.Diner {
+	return monitor.Diner{
+		FirstName: b.FirstName,
+		LastName:  b.LastName,
+		Email:     b.Email,
+		Phone:     b.Phone,
+		Country:   b.Country,
+		Notes:     b.Notes,
+	}
+}
+
+// Retry tunes how watches ride out failed polls. Zero values take the
+// monitor's defaults.
+type Retry struct {
+	Backoff     time.Duration `yaml:"backoff"`       // first wait, doubling after each failure; default 15s
+	MaxBackoff  time.Duration `yaml:"max_backoff"`   // longest wait; default 10m
+	AlertAfter  int           `yaml:"alert_after"`   // failures in a row before a "degraded" alert; default 5
+	GiveUpAfter int           `yaml:"give_up_after"` // failures in a row before the watch stops; 0 = never
+}
+
+// Policy returns the settings in the form the monitor package uses.
+func (r Retry) Policy() monitor.RetryPolicy {
+	return monitor.RetryPolicy{
+		Backoff:     r.Backoff,
+		MaxBackoff:  r.MaxBackoff,
+		AlertAfter:  r.AlertAfter,
+		GiveUpAfter: r.GiveUpAfter,
+	}
+}
+
+// Proxies is the pool requests are spread over: the entries of List and
+// of File (one per line), as accepted by monitor.ParseProxy.
+type Proxies struct {
+	File string   `yaml:"file"`
+	List []string `yaml:"list"`
+	Mode string   `yaml:"mode"` // round-robin (default) or sticky: one proxy per watch
+}
+
+// Pool builds the proxy pool, or returns nil when none is configured.
+func (p Proxies) Pool() (*monitor.ProxyPool, error) {
+	addrs := slices.Clone(p.List)
+	if p.File != "" {
+		more, err := monitor.ReadProxies(p.File)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, more...)
+	}
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	return monitor.NewProxyPool(addrs, monitor.ProxyMode(p.Mode))
+}
+
+// Notifier is one named alert target. Only the fields relevant to Type
+// are used.
+type Notifier struct {
+	Type string `yaml:"type"` // "discord", "slack", "email" or "webhook"
+	URL  string `yaml:"url"`
+
+	// discord only
+	Queue string `yaml:"queue"` // file for undelivered messages; empty = retry inline
+
+	// webhook only
+	Secret string `yaml:"secret"` // HMAC-SHA256 signing key
+
+	// email only
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Security string   `yaml:"security"` // starttls (default), tls or none
+
+	line int
+}
+
+// Watch is one entry under "watches". Restaurant holds either a numeric
+// OpenTable ID or a name to resolve through autocomplete.
+type Watch struct {
+	ID          string        `yaml:"id"`
+	Restaurant  string        `yaml:"restaurant"`
+	Date        string        `yaml:"date"`
+	Dates       []string      `yaml:"dates"`
+	Range       string        `yaml:"range"` // e.g. "fri,sat next 6w", "2025-11-01..2025-11-15"
+	Time        string        `yaml:"time"`
+	Times       []string      `yaml:"times"`     // ranked preferred times, best first
+	Tolerance   time.Duration `yaml:"tolerance"` // ± around each preferred time
+	Window      string        `yaml:"window"`    // "HH:MM-HH:MM", any time inside matches
+	Policy      string        `yaml:"policy"`    // "stop" (default) or "continue"
+	Include     []string      `yaml:"include"`   // seating attributes to require (any of)
+	Exclude     []string      `yaml:"exclude"`   // seating attributes to reject
+	Inventory   string        `yaml:"inventory"` // "standard", "experience" or "" for both
+	Party       int           `yaml:"party"`
+	PartyMax    int           `yaml:"party_max"` // also accept parties up to this size
+	Interval    time.Duration `yaml:"interval"`
+	Jitter      time.Duration `yaml:"jitter"`         // ± random offset on every poll
+	Fast        []string      `yaml:"fast"`           // faster polling, e.g. "10:00+15m@5s"
+	Quiet       string        `yaml:"quiet"`          // "HH:MM-HH:MM" to poll slowly, e.g. overnight
+	QuietEvery  time.Duration `yaml:"quiet_interval"` // default 10m
+	FarDays     int           `yaml:"far_days"`       // dates further out than this poll slowly…
+	FarEvery    time.Duration `yaml:"far_interval"`   // …at this interval; default 5m
+	TZ          string        `yaml:"tz"`             // zone of fast/quiet times, e.g. America/Toronto
+	AutoBook    bool          `yaml:"autobook"`       // reserve the best match under "booking"
+	Current     string        `yaml:"current"`        // upgrade mode: time of a booking held, to beat
+	Reservation string        `yaml:"reservation"`    // upgrade mode: ID or confirmation number of the booking
+	Notify      []string      `yaml:"notify"`         // notifier names; empty = all
+
+	Line int `yaml:"-"` // line of the entry in the file
+
+	lines map[string]int // key → line, for pinpointing field errors
+}
+
+// lineOf returns the line of key within the entry, or the entry's line.
+func (w Watch) lineOf(key string) int {
+	if l, ok := w.lines[key]; ok {
+		return l
+	}
+	return w.Line
+}
+
+// Error is a validation problem pinned to a place in the watch file.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// DefaultDiscordNotifier is the name given to the notifier implied by the
+// DISCORD_WEBHOOK_URL environment variable when the file declares none.
+const DefaultDiscordNotifier = "discord"
+
+// Load reads, parses and validates the watch file at path. ${VAR}
+// references in values are expanded from the environment (including
+// .env). Every validation problem is reported, joined, with file:line
+// context.
+func Load(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, &Error{File: path, Line: 1, Msg: "file is empty"}
+	}
+	root := doc.Content[0]
+	expandEnv(root)
+
+	cfg := &Config{path: path}
+	if err := root.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// remember where each entry lives so errors can point at it
+	if n := mapValue(root, "interval"); n != nil {
+		cfg.intervalLine = n.Line
+	}
+	if n := mapValue(root, "booking"); n != nil {
+		cfg.bookingLine = n.Line
+	}
+	if n := mapValue(root, "retry"); n != nil {
+		cfg.retryLine = n.Line
+	}
+	if n := mapValue(root, "queries"); n != nil {
+		cfg.queriesLine = n.Line
+	}
+	if n := mapValue(root, "proxies"); n != nil {
+		cfg.proxiesLine = n.Line
+	}
+	if n := mapValue(root, "watches"); n != nil && n.Kind == yaml.SequenceNode {
+		for i, item := range n.Content {
+			if i >= len(cfg.Watches) {
+				break
+			}
+			cfg.Watches[i].Line = item.Line
+			cfg.Watches[i].lines = map[string]int{}
+			for j := 0; j+1 < len(item.Content); j += 2 {
+				cfg.Watches[i].lines[item.Content[j].Value] = item.Content[j].Line
+			}
+		}
+	}
+	if n := mapValue(root, "notifiers"); n != nil && n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			name := n.Content[i].Value
+			if nt, ok := cfg.Notifiers[name]; ok {
+				nt.line = n.Content[i].Line
+				cfg.Notifiers[name] = nt
+			}
+		}
+	}
+
+	if len(cfg.Notifiers) == 0 {
+		if u := os.Getenv("DISCORD_WEBHOOK_URL"); u != "" {
+			cfg.Notifiers = map[string]Notifier{
+				DefaultDiscordNotifier: {Type: "discord", URL: u, line: 1},
+			}
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate checks every watch and notifier, collecting all problems.
+func (c *Config) validate() error {
+	var errs []error
+	fail := func(line int, format string, args ...any) {
+		errs = append(errs, &Error{File: c.path, Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Interval < 0 {
+		fail(c.intervalLine, "interval must be positive")
+	}
+	if err := c.Retry.Policy().Validate(); err != nil {
+		fail(c.retryLine, "%v", err)
+	}
+	names := make([]string, 0, len(c.Queries))
+	for name := range c.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := monitor.CheckQuery(name, c.Queries[name]); err != nil {
+			fail(c.queriesLine, "queries: %v", err)
+		}
+	}
+	if _, err := c.Proxies.Pool(); err != nil {
+		fail(c.proxiesLine, "proxies: %v", err)
+	}
+
+	for _, name := range c.notifierNames() {
+		n := c.Notifiers[name]
+		switch n.Type {
+		case "discord", "slack":
+			if !strings.HasPrefix(n.URL, "https://") {
+				fail(n.line, "notifier %q: url must be an https webhook URL", name)
+			}
+		case "webhook":
+			if !strings.HasPrefix(n.URL, "https://") && !strings.HasPrefix(n.URL, "http://") {
+				fail(n.line, "notifier %q: url must be an http(s) URL", name)
+			}
+		case "email":
+			if n.Host == "" || n.From == "" || len(n.To) == 0 {
+				fail(n.line, "notifier %q: host, from and to are required", name)
+			}
+			switch n.Security {
+			case "", "starttls", "tls", "none":
+			default:
+				fail(n.line, "notifier %q: security must be starttls, tls or none", name)
+			}
+		case "":
+			fail(n.line, "notifier %q: missing type", name)
+		default:
+			fail(n.line, "notifier %q: unknown type %q", name, n.Type)
+		}
+	}
+
+	if len(c.Watches) == 0 {
+		fail(1, "no watches defined")
+	}
+	// moving a reservation uses the account's own profile
+	if slices.ContainsFunc(c.Watches, func(w Watch) bool { return w.AutoBook && w.Reservation == "" }) {
+		line := c.bookingLine
+		if line == 0 {
+			line = 1
+		}
+		if err := c.Booking.Diner().Validate(); err != nil {
+			fail(line, "booking: %v (needed by autobook watches)", err)
+		}
+	}
+	ids := map[string]int{}
+	for i := range c.Watches {
+		w := &c.Watches[i]
+		if w.ID == "" {
+			w.ID = fmt.Sprintf("watch-%d", i+1)
+		}
+		if prev, dup := ids[w.ID]; dup {
+			fail(w.lineOf("id"), "watch %q: duplicate id (first defined on line %d)", w.ID, prev)
+		}
+		ids[w.ID] = w.Line
+
+		upgrading := w.Reservation != ""
+		if strings.TrimSpace(w.Restaurant) == "" && !upgrading {
+			fail(w.Line, "watch %q: restaurant is required", w.ID)
+		}
+		dateKey := "dates"
+		if w.Date != "" {
+			dateKey = "date"
+			w.Dates = append([]string{w.Date}, w.Dates...)
+			w.Date = ""
+		}
+		if len(w.Dates) == 0 && w.Range == "" && !upgrading {
+			fail(w.Line, "watch %q: date, dates or range is required", w.ID)
+		}
+		if w.Range != "" {
+			if _, err := monitor.ParseDateSpec(w.Range); err != nil {
+				fail(w.lineOf("range"), "watch %q: invalid range: %v", w.ID, err)
+			}
+		}
+		for _, d := range w.Dates {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				fail(w.lineOf(dateKey), "watch %q: invalid date %q (want YYYY-MM-DD)", w.ID, d)
+			}
+		}
+		if w.Time == "" && len(w.Times) > 0 {
+			w.Time = w.Times[0]
+		}
+
+		// everything the monitor itself checks, pinned to the key behind it;
+		// restaurant, dates and the retry block are checked above
+		for _, err := range splitErrors(c.MonitorWatch(*w).Validate()) {
+			var fe *monitor.FieldError
+			if !errors.As(err, &fe) {
+				fail(w.Line, "watch %q: %v", w.ID, err)
+				continue
+			}
+			switch {
+			case fe.Field == "restaurantId" || fe.Field == "date" || fe.Field == "retry":
+			case fe.Field == "partySize" && upgrading: // taken from the reservation
+			default:
+				fail(w.lineOf(watchKeys[fe.Field]), "watch %q: %v", w.ID, fe.Err)
+			}
+		}
+		switch {
+		case w.Current != "" && upgrading:
+			fail(w.lineOf("current"), "watch %q: use current or reservation, not both", w.ID)
+		case (w.Current != "" || upgrading) && (w.Range != "" || len(w.Dates) > 1):
+			fail(w.lineOf("range"), "watch %q: an upgrade watch covers the single date of its booking", w.ID)
+		case w.Current != "" && w.AutoBook:
+			fail(w.lineOf("autobook"), "watch %q: autobook on an upgrade watch needs reservation, not just current", w.ID)
+		}
+		// Schedule drops fast windows it can't parse, so report them here
+		for _, f := range w.Fast {
+			if _, err := monitor.ParseFastWindow(f); err != nil {
+				fail(w.lineOf("fast"), "watch %q: %v", w.ID, err)
+			}
+		}
+		for _, name := range w.Notify {
+			if _, ok := c.Notifiers[name]; !ok {
+				fail(w.lineOf("notify"), "watch %q: unknown notifier %q", w.ID, name)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchKeys maps the monitor.Watch fields a FieldError names to the watch
+// key that sets them.
+var watchKeys = map[string]string{
+	"time":                "time",
+	"partySize":           "party",
+	"partyMax":            "party_max",
+	"interval":            "interval",
+	"policy":              "policy",
+	"match.preferred":     "times",
+	"match.tolerance":     "tolerance",
+	"match.windowStart":   "window",
+	"match.windowEnd":     "window",
+	"filter.inventory":    "inventory",
+	"filter.exclude":      "exclude",
+	"upgrade.current":     "current",
+	"schedule.jitter":     "jitter",
+	"schedule.fast":       "fast",
+	"schedule.quiet":      "quiet",
+	"schedule.quietEvery": "quiet_interval",
+	"schedule.farDays":    "far_days",
+	"schedule.farEvery":   "far_interval",
+	"schedule.tz":         "tz",
+}
+
+// splitErrors unpacks an errors.Join result into its parts.
+func splitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
+
+// MonitorWatch returns w in the form the monitor package runs, with the
+// file's retry settings. What needs looking up first is left to the
+// caller: RestaurantID for a name, Date, the held Reservation and Booker.
+func (c *Config) MonitorWatch(w Watch) monitor.Watch {
+	mw := monitor.Watch{
+		ID:           w.ID,
+		RestaurantID: w.RestaurantID(),
+		TimePref:     w.Time,
+		PartySize:    w.Party,
+		PartyMax:     w.PartyMax,
+		Interval:     w.Interval,
+		Match:        w.Match(),
+		Policy:       monitor.MatchPolicy(w.Policy),
+		Filter:       w.Filter(),
+		Schedule:     w.Schedule(),
+		Retry:        c.Retry.Policy(),
+	}
+	if w.Current != "" {
+		mw.Upgrade = &monitor.Upgrade{Current: w.Current}
+	}
+	return mw
+}
+
+// Match returns the watch's matching options.
+func (w Watch) Match() monitor.TimeMatch {
+	m := monitor.TimeMatch{Preferred: w.Times, Tolerance: w.Tolerance}
+	if w.Window != "" {
+		m.WindowStart, m.WindowEnd = w.WindowBounds()
+	}
+	return m
+}
+
+// RestaurantID returns the numeric ID when Restaurant is one, or "" when
+// it is a name that still needs resolving.
+func (w Watch) RestaurantID() string {
+	if _, err := strconv.Atoi(strings.TrimSpace(w.Restaurant)); err == nil {
+		return strings.TrimSpace(w.Restaurant)
+	}
+	return ""
+}
+
+// Filter returns the watch's seating rules.
+func (w Watch) Filter() monitor.SlotFilter {
+	inv := monitor.Inventory(w.Inventory)
+	if inv == "any" {
+		inv = monitor.InventoryAny
+	}
+	return monitor.SlotFilter{Include: w.Include, Exclude: w.Exclude, Inventory: inv}
+}
+
+// WindowBounds splits Window into its start and end times.
+func (w Watch) WindowBounds() (from, to string) {
+	return splitSpan(w.Window)
+}
+
+// splitSpan splits "HH:MM-HH:MM" into its two times.
+func splitSpan(s string) (from, to string) {
+	from, to, _ = strings.Cut(s, "-")
+	return strings.TrimSpace(from), strings.TrimSpace(to)
+}
+
+// Schedule returns the watch's polling rules.
+func (w Watch) Schedule() monitor.Schedule {
+	sc := monitor.Schedule{
+		Jitter:     w.Jitter,
+		Quiet:      w.Quiet,
+		QuietEvery: w.QuietEvery,
+		FarDays:    w.FarDays,
+		FarEvery:   w.FarEvery,
+		TZ:         w.TZ,
+	}
+	for _, f := range w.Fast {
+		if fw, err := monitor.ParseFastWindow(f); err == nil {
+			sc.Fast = append(sc.Fast, fw)
+		}
+	}
+	return sc
+}
+
+// DateSpec returns the dates the watch covers when it uses a range,
+// with any explicit dates folded in. ok is false for plain date lists.
+func (w Watch) DateSpec() (spec monitor.DateSpec, ok bool) {
+	if w.Range == "" {
+		return spec, false
+	}
+	spec, _ = monitor.ParseDateSpec(w.Range)
+	spec.Dates = append(spec.Dates, w.Dates...)
+	return spec, true
+}
+
+// NotifierNames lists the notifiers this watch alerts, defaulting to all.
+func (c *Config) NotifierNames(w Watch) []string {
+	if len(w.Notify) > 0 {
+		return w.Notify
+	}
+	return c.notifierNames()
+}
+
+func (c *Config) notifierNames() []string {
+	names := make([]string, 0, len(c.Notifiers))
+	for name := range c.Notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envRef is a ${VAR} reference in a watch file.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references in every scalar under n. Only
+// the braced form is expanded, so a bare $ (in a password, say) is kept,
+// and it runs after parsing so lines stay those of the file.
+func expandEnv(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && strings.Contains(n.Value, "${") {
+		n.Value = envRef.ReplaceAllStringFunc(n.Value, func(ref string) string {
+			return os.Getenv(ref[2 : len(ref)-1])
+		})
+		if n.Style == 0 {
+			n.Tag = "" // resolve again: party: ${PARTY} is a number once expanded
+		}
+	}
+	for _, c := range n.Content {
+		expandEnv(c)
+	}
+}
+
+// mapValue returns the value node stored under key in a mapping node.
+func mapValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPinsWatchErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watches.yaml")
+	doc := `notifiers:
+  hook:
+    type: webhook
+    url: http://localhost/hook
+watches:
+  - id: nopa
+    restaurant: "1234"
+    date: 2026-11-02
+    time: "19:30"
+    window: 21:00-20:00
+    party: 2
+    policy: sometimes
+    quiet: late
+    tz: Mars/Olympus
+    tolerance: -5m
+`
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load accepted a broken watch")
+	}
+	for _, want := range []string{
+		":10: watch \"nopa\": window 21:00-20:00 ends before it starts",
+		":12: watch \"nopa\": unknown policy",
+		":13: watch \"nopa\": quiet hours \"late\"",
+		":14: watch \"nopa\": time zone \"Mars/Olympus\"",
+		":15: watch \"nopa\": tolerance must not be negative",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("errors lack %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadUpgradeWatchNeedsNoParty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watches.yaml")
+	doc := `notifiers:
+  hook:
+    type: webhook
+    url: http://localhost/hook
+watches:
+  - reservation: C-1234
+    time: "19:00"
+`
+	if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+}
@@ -2,291 +2,262 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 
 	"opentable-monitor/monitor"
 	"opentable-monitor/notifications"
-
-	"github.com/charmbracelet/huh"
-	"github.com/charmbracelet/huh/spinner"
 )
 
-func themed(f *huh.Form) *huh.Form { return f.WithTheme(huh.ThemeCharm()) }
+const usage = `Usage: opentable-monitor [command] [flags]
+
+With no command the interactive TUI starts when attached to a terminal.
+
+Commands:
+  tui                         interactive search & monitor (default)
+  search <term>               list matching restaurants as JSON
+  availability [flags]        print open slots as JSON
+  watch [flags]               monitor until the slot is found; events as JSON lines
+  run [-config watches.yaml]  monitor every watch declared in a config file
+  resume [-state file]        restart the unfinished watches of a state file
+  login [flags]               sign in to OpenTable and save the session
+  reservations                list your upcoming reservations as JSON
+  cancel -id <ref>            cancel a reservation (ID or confirmation number)
+  modify -id <ref> [flags]    move a reservation to another time/date/party size
+  upgrade [flags]             watch for a better time than a booking you hold
+  snipe [flags]               burst-poll the moment a restaurant releases a date
+  queries [-discover [-save]] show or refresh the GraphQL query hashes in use
+  proxies [-file list]        test every proxy of a list and print its stats
+
+Run "opentable-monitor <command> -h" for the flags of a command.
+`
 
-//  Label helpers
+func main() {
+	_ = godotenv.Load()
 
-func trunc(s string, n int) string {
-	if len(s) > n {
-		return s[:n-1] + "…"
+	args := os.Args[1:]
+	if len(args) == 0 {
+		if !isTerminal(os.Stdin) {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		args = []string{"tui"}
 	}
-	return s
-}
 
-func menuLabel(r monitor.AutoResult) string {
-	return fmt.Sprintf("%-40s | %-20s | %-16s | %-12s | %-10s | %s",
-		trunc(r.Name, 40),
-		trunc(r.Neighborhood, 20),
-		trunc(r.Metro, 16),
-		r.Country,
-		r.Type,
-		r.ID,
-	)
-}
-
-func confirmLabel(r monitor.AutoResult) string {
-	return strings.Join([]string{
-		r.Name, r.Neighborhood, r.Metro, r.Country, r.Type, r.ID,
-	}, " | ")
-}
+	cmd, args := args[0], args[1:]
+	var run func([]string) error
+	switch cmd {
+	case "tui":
+		run = cmdTUI
+	case "search":
+		run = cmdSearch
+	case "availability":
+		run = cmdAvailability
+	case "watch":
+		run = cmdWatch
+	case "run":
+		run = cmdRun
+	case "resume":
+		run = cmdResume
+	case "login":
+		run = cmdLogin
+	case "reservations":
+		run = cmdReservations
+	case "cancel":
+		run = cmdCancel
+	case "modify":
+		run = cmdModify
+	case "upgrade":
+		run = cmdUpgrade
+	case "snipe":
+		run = cmdSnipe
+	case "queries":
+		run = cmdQueries
+	case "proxies":
+		run = cmdProxies
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
 
-//  Time-slot builder
+	// headless output must stay machine-readable on stdout
+	if cmd != "tui" {
+		monitor.Output = os.Stderr
+	}
 
-func buildTimes() []string {
-	times := make([]string, 0, 48)
-	for h := range 24 {
-		for _, m := range []int{0, 30} {
-			times = append(times, fmt.Sprintf("%02d:%02d", h, m))
+	if err := run(args); err != nil {
+		if err == flag.ErrHelp {
+			return
 		}
+		log.Fatalf("%s: %v", cmd, err)
 	}
-	return times
 }
 
-//  Main loop
-
-func main() {
-	_ = godotenv.Load()
+// newClient performs the CSRF + geo setup every command needs and signs
+// in when a saved session or OPENTABLE_EMAIL/OPENTABLE_PASSWORD exist.
+// Requests go through pool, or when it is nil through OPENTABLE_PROXIES
+// if set. A session that fails to load only costs member-only features.
+func newClient(pool *monitor.ProxyPool) (*monitor.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Get Discord webhook URL from environment variable
-	webhookURL := os.Getenv("DISCORD_WEBHOOK_URL")
-	if webhookURL == "" {
-		log.Fatalf("DISCORD_WEBHOOK_URL environment variable is required")
+	if pool == nil {
+		var err error
+		if pool, err = envProxies(); err != nil {
+			return nil, err
+		}
 	}
-
-	// Initialize Discord notifier
-	discord := notifications.NewDiscordNotifier(webhookURL)
-
-	cli, err := monitor.New(ctx)
+	if pool != nil {
+		fmt.Fprintf(monitor.Output, "🌐  Sending requests through %d proxies (%s)\n", len(pool.Stats()), pool.Mode())
+	}
+	cli, err := monitor.New(ctx, pool)
 	if err != nil {
-		log.Fatalf("monitor init: %v", err)
+		return nil, fmt.Errorf("monitor init: %w", err)
+	}
+	if err := monitor.LoadQueries(queriesPath()); err != nil {
+		log.Printf("%v (using the built-in query hashes)", err)
 	}
 
-	for {
-		// search term
-		var term string
-		if err := themed(huh.NewForm(
-			huh.NewGroup(
-				huh.NewInput().
-					Title("🔍  Restaurant search (blank = quit)").
-					Placeholder("House of Prime Rib").
-					Value(&term),
-			))).Run(); err != nil || strings.TrimSpace(term) == "" {
-			fmt.Println("Bye!")
-			return
-		}
-
-		// fetch results
-		var results []monitor.AutoResult
-		var fetchErr error
-
-		_ = spinner.New().
-			Title("Fetching results…").
-			Context(ctx).
-			Action(func() {
-				results, fetchErr = cli.Autocomplete(ctx, term)
-			}).
-			Run()
-
-		if fetchErr != nil {
-			log.Printf("autocomplete: %v\n", fetchErr)
-			continue
-		}
-		if len(results) == 0 {
-			fmt.Println("No matches – try again.")
-			continue
-		}
-
-		// restaurant select
-		resOpts := make([]huh.Option[string], 0, len(results)+1)
-		for _, r := range results {
-			resOpts = append(resOpts, huh.NewOption(menuLabel(r), r.ID))
-		}
-		resOpts = append(resOpts, huh.NewOption("🔄  New search", "redo"))
-
-		var pickedID string
-		if err := themed(huh.NewForm(
-			huh.NewGroup(
-				huh.NewSelect[string]().
-					Title("Select restaurant (↑/↓, ⏎)").
-					Options(resOpts...).
-					Height(12).
-					Value(&pickedID),
-			))).Run(); err != nil {
-			fmt.Println("Selection aborted.")
-			return
-		}
-		if pickedID == "redo" {
-			continue
-		}
-
-		var picked monitor.AutoResult
-		for _, r := range results {
-			if r.ID == pickedID {
-				picked = r
-				break
-			}
-		}
-
-		// date select
-		var datePref string
-		if err := themed(huh.NewForm(
-			huh.NewGroup(
-				huh.NewInput().
-					Title("🗓️  Reservation date (YYYY-MM-DD)").
-					Placeholder(time.Now().Format("2006-01-02")).
-					Validate(func(v string) error {
-						v = strings.TrimSpace(v)
-						if _, err := time.Parse("2006-01-02", v); err != nil {
-							return fmt.Errorf("invalid date format")
-						}
-						return nil
-					}).
-					Value(&datePref),
-			))).Run(); err != nil {
-			fmt.Println("Selection aborted.")
-			return
-		}
+	path, creds := sessionPath(), envCredentials()
+	if _, err := os.Stat(path); err != nil && creds.Password == "" {
+		return cli, nil // never signed in
+	}
+	if err := cli.UseSession(path, creds); err != nil {
+		log.Printf("session: %v (continuing signed out)", err)
+		return cli, nil
+	}
+	if err := cli.RefreshSession(ctx); err != nil {
+		log.Printf("%v (continuing signed out)", err)
+		return cli, nil
+	}
+	fmt.Fprintf(monitor.Output, "🔑  Signed in as %s\n", cli.User())
+	return cli, nil
+}
 
-		// time-slot select
-		timeOpts := buildTimes()
-		tsOpts := make([]huh.Option[string], len(timeOpts))
-		for i, t := range timeOpts {
-			tsOpts[i] = huh.NewOption(t, t)
-		}
+// sessionPath is where the signed-in cookie jar is kept.
+func sessionPath() string {
+	if p := os.Getenv("OPENTABLE_SESSION"); p != "" {
+		return p
+	}
+	return "opentable-session.json"
+}
 
-		var timePref string
-		if err := themed(huh.NewForm(
-			huh.NewGroup(
-				huh.NewSelect[string]().
-					Title("⏰  Preferred reservation time (24-hour)").
-					Description("We'll ping you for the closest available slots.").
-					Options(tsOpts...).
-					Height(10).
-					Value(&timePref),
-			))).Run(); err != nil {
-			fmt.Println("Selection aborted.")
-			return
-		}
+// queriesPath is where query hashes found by "queries -discover -save"
+// are kept.
+func queriesPath() string {
+	if p := os.Getenv("OPENTABLE_QUERIES"); p != "" {
+		return p
+	}
+	return "opentable-queries.json"
+}
 
-		// party size
-		var partySizeStr string
-		var partySize int
+// envProxies loads the proxy list named by OPENTABLE_PROXIES, if any.
+func envProxies() (*monitor.ProxyPool, error) {
+	path := os.Getenv("OPENTABLE_PROXIES")
+	if path == "" {
+		return nil, nil
+	}
+	return monitor.LoadProxies(path, monitor.ProxyMode(os.Getenv("OPENTABLE_PROXY_MODE")))
+}
 
-		// Use string for the form
-		form := huh.NewForm(
-			huh.NewGroup(
-				huh.NewInput().
-					Title("👥  Party size").
-					Placeholder("2").
-					Validate(func(v string) error {
-						if _, err := strconv.Atoi(v); err != nil {
-							return fmt.Errorf("must be a valid number")
-						}
-						return nil
-					}).
-					Value(&partySizeStr), // Use string pointer here
-			),
-		)
+// envCredentials reads the OpenTable account to sign in with, if any.
+func envCredentials() monitor.Credentials {
+	return monitor.Credentials{
+		Email:    os.Getenv("OPENTABLE_EMAIL"),
+		Password: os.Getenv("OPENTABLE_PASSWORD"),
+	}
+}
 
-		// After form submission, convert to int
-		if err := form.Run(); err == nil {
-			partySize, _ = strconv.Atoi(partySizeStr)
-		}
+// cmdTUI starts the interactive forms. A notifier is mandatory here.
+func cmdTUI(_ []string) error {
+	notifier := envNotifier()
+	if notifier == nil {
+		return fmt.Errorf("DISCORD_WEBHOOK_URL, SLACK_WEBHOOK_URL, WEBHOOK_URL or SMTP_HOST environment variable is required")
+	}
 
-		// start-monitor confirmation
-		var start bool
-		if err := themed(huh.NewForm(
-			huh.NewGroup(
-				huh.NewConfirm().
-					Title("Start monitor?").
-					Affirmative("Yes").
-					Negative("No").
-					Value(&start),
-			))).Run(); err != nil {
-			fmt.Println("Aborted.")
-			return
-		}
-		if !start {
-			fmt.Println("\nMonitor cancelled.")
-			return
-		}
+	cli, err := newClient(nil)
+	if err != nil {
+		return err
+	}
+	runTUI(cli, notifier)
+	return nil
+}
 
-		// final message
-		fmt.Printf("\n✅  Monitoring: %s\n", confirmLabel(picked))
-		fmt.Printf("   Preferred date : %s\n", datePref)
-		fmt.Printf("   Preferred time : %s\n", timePref)
-		fmt.Printf("   Party size     : %d\n", partySize)
-		fmt.Printf("   Discord webhook configured: %s\n\n", "✅")
+// envNotifier builds the notifier configured through environment
+// variables, or nil when none is set. It delivers in the background.
+func envNotifier() notifications.Notifier {
+	var multi notifications.Multi
 
-		// Send initial monitoring started webhook
-		if err := discord.SendMonitoringStarted(picked, datePref, timePref, partySize); err != nil {
-			log.Printf("Failed to send start webhook: %v", err)
+	// Get Discord webhook URL from environment variable
+	if u := os.Getenv("DISCORD_WEBHOOK_URL"); u != "" {
+		d := notifications.NewDiscordNotifier(u)
+		if path := os.Getenv("DISCORD_QUEUE_FILE"); path != "" {
+			if _, err := d.EnableQueue(path); err != nil {
+				log.Printf("discord queue disabled: %v", err)
+			}
 		}
+		multi = append(multi, d)
+	}
+	if u := os.Getenv("SLACK_WEBHOOK_URL"); u != "" {
+		multi = append(multi, notifications.NewSlackNotifier(u))
+	}
+	if u := os.Getenv("WEBHOOK_URL"); u != "" {
+		multi = append(multi, notifications.NewWebhookNotifier(u, os.Getenv("WEBHOOK_SECRET")))
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		multi = append(multi, notifications.NewEmailNotifier(notifications.EmailConfig{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			To:       splitList(os.Getenv("SMTP_TO")),
+			Security: os.Getenv("SMTP_SECURITY"),
+		}))
+	}
 
-		// START THE BACKGROUND MONITOR
-		// Use a fresh, cancellable context so the monitor isn't limited to 30 s.
-		monitorCtx, stop := context.WithCancel(context.Background())
-		defer stop() // ensures cleanup if main returns for any reason
+	switch len(multi) {
+	case 0:
+		return nil
+	case 1:
+		return notifications.NewAsync(multi[0], logNotifyErr)
+	default:
+		return notifications.NewAsync(multi, logNotifyErr)
+	}
+}
 
-		// Run the monitor inside a spinner for a nicer UX.
-		_ = spinner.New().
-			Title("Running reservation monitor… (Ctrl-C to quit)").
-			Context(monitorCtx).
-			Action(func() {
-				// Use the modified StartMonitor with callback
-				if err := cli.StartMonitor(
-					monitorCtx,
-					picked.ID,
-					datePref,
-					timePref,
-					partySize,
-					func(exactMatch bool, reservationURL string, alternativeTimes []string) {
-						if exactMatch {
-							// Send exact slot found webhook
-							if err := discord.SendSlotFound(picked, datePref, timePref, partySize, reservationURL); err != nil {
-								log.Printf("Failed to send slot found webhook: %v", err)
-							}
-						} else if len(alternativeTimes) > 0 {
-							// Send alternative times webhook
-							if err := discord.SendAlternativeTimes(picked, datePref, partySize, alternativeTimes, reservationURL); err != nil {
-								log.Printf("Failed to send alternative times webhook: %v", err)
-							}
-						}
-					},
-				); err != nil {
-					// Send error notification
-					if err := discord.SendError(picked, err.Error()); err != nil {
-						log.Printf("Failed to send error webhook: %v", err)
-					}
-					log.Printf("monitor: %v\n", err)
-				}
-			}).
-			Run()
+// logNotifyErr logs, rather than aborts on, a failed delivery.
+func logNotifyErr(ev notifications.Event, err error) {
+	log.Printf("Failed to send %s notification: %v", ev.Type, err)
+}
 
-		// Send monitoring stopped notification
-		if err := discord.SendMonitoringStopped(picked, "Monitor completed or cancelled"); err != nil {
-			log.Printf("Failed to send stop webhook: %v", err)
-		}
+// envDiner reads the auto-book diner profile from DINER_* variables.
+func envDiner() monitor.Diner {
+	return monitor.Diner{
+		FirstName: os.Getenv("DINER_FIRST_NAME"),
+		LastName:  os.Getenv("DINER_LAST_NAME"),
+		Email:     os.Getenv("DINER_EMAIL"),
+		Phone:     os.Getenv("DINER_PHONE"),
+		Country:   os.Getenv("DINER_COUNTRY"),
+		Notes:     os.Getenv("DINER_NOTES"),
+	}
+}
 
-		// After monitor exits (slot found or ctx cancelled) we're done.
-		return
+// isTerminal reports whether f is an interactive character device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
 	}
+	return fi.Mode()&os.ModeCharDevice != 0
 }
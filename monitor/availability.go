@@ -1,15 +1,12 @@
 package monitor
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
-
-	http "github.com/bogdanfinn/fhttp"
 )
 
 type slotInfo struct {
@@ -19,16 +16,128 @@ type slotInfo struct {
 	PointsValue int
 	Attributes  []string
 	IsMandatory bool
+	Experience  bool   // experience inventory rather than a standard table
+	Party       int    // party size the slot was offered for; 0 = the caller's
+	Token       string // availability token it came with; "" = the caller's
+}
+
+// key identifies a slot for one party size: the same time can come back
+// with the same hash for several sizes.
+func (s slotInfo) key() string {
+	return s.SlotHash + "@" + strconv.Itoa(s.Party)
 }
 
 func (s slotInfo) buildURL(date string, party int, token string, rid int) string {
+	if s.Party > 0 {
+		party = s.Party
+	}
+	if s.Token != "" {
+		token = s.Token
+	}
 	dateTime := url.QueryEscape(fmt.Sprintf("%sT%s:00", date, s.Time))
 	return fmt.Sprintf(
-		"https://www.opentable.ca/booking/details?availabilityToken=%s&dateTime=%s&partySize=%d&points=%d&pointsType=%s&rid=%d&slotHash=%s&isModify=false&isMandatory=%t&cfe=true",
-		token, dateTime, party, s.PointsValue, s.PointsType, rid, s.SlotHash, s.IsMandatory,
+		"%s/booking/details?availabilityToken=%s&dateTime=%s&partySize=%d&points=%d&pointsType=%s&rid=%d&slotHash=%s&isModify=false&isMandatory=%t&cfe=true",
+		BaseURL, token, dateTime, party, s.PointsValue, s.PointsType, rid, s.SlotHash, s.IsMandatory,
 	)
 }
 
+// Slot is one bookable time as returned by FetchAvailability.
+type Slot struct {
+	RestaurantID int      `json:"restaurantId"`
+	Date         string   `json:"date"`
+	Time         string   `json:"time"`
+	PartySize    int      `json:"partySize"`
+	SlotHash     string   `json:"slotHash"`
+	PointsType   string   `json:"pointsType"`
+	PointsValue  int      `json:"pointsValue"`
+	Attributes   []string `json:"attributes"`
+	IsMandatory  bool     `json:"isMandatory"`
+	Experience   bool     `json:"experience"`
+	Token        string   `json:"token,omitempty"` // availability token, needed to book
+	URL          string   `json:"url"`
+}
+
+// export turns the raw slot into the public shape, booking link included.
+func (s slotInfo) export(rid int, date string, party int, token string) Slot {
+	if s.Party > 0 {
+		party = s.Party
+	}
+	if s.Token != "" {
+		token = s.Token
+	}
+	return Slot{
+		RestaurantID: rid,
+		Date:         date,
+		Time:         s.Time,
+		PartySize:    party,
+		SlotHash:     s.SlotHash,
+		PointsType:   s.PointsType,
+		PointsValue:  s.PointsValue,
+		Attributes:   s.Attributes,
+		IsMandatory:  s.IsMandatory,
+		Experience:   s.Experience,
+		Token:        token,
+		URL:          s.buildURL(date, party, token, rid),
+	}
+}
+
+// info is the inverse of export, used when restoring persisted slots.
+func (s Slot) info() slotInfo {
+	return slotInfo{
+		Time:        s.Time,
+		SlotHash:    s.SlotHash,
+		PointsType:  s.PointsType,
+		PointsValue: s.PointsValue,
+		Attributes:  s.Attributes,
+		IsMandatory: s.IsMandatory,
+		Experience:  s.Experience,
+		Party:       s.PartySize,
+		Token:       s.Token,
+	}
+}
+
+// AvailabilityQuery describes one batched RestaurantsAvailability call:
+// every restaurant in RestaurantIDs, from Date through Date+ForwardDays.
+type AvailabilityQuery struct {
+	RestaurantIDs []int
+	Date          string // first day, YYYY-MM-DD
+	ForwardDays   int    // extra days after Date (0 = Date only)
+	Time          string // HH:MM the slot offsets are anchored to
+	PartySize     int
+}
+
+// Availability maps restaurant ID → calendar date → open slots.
+type Availability map[int]map[string][]Slot
+
+// restaurantAvail is the decoded availability of one restaurant.
+type restaurantAvail struct {
+	token string
+	days  map[string][]slotInfo // key = YYYY-MM-DD
+}
+
+// FetchAvailability asks for many restaurants over a multi-day window in a
+// single POST and returns the open slots keyed by restaurant and date.
+func (c *Client) FetchAvailability(ctx context.Context, q AvailabilityQuery) (Availability, error) {
+	raw, err := c.fetchAvailability(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Availability, len(raw))
+	for rid, ra := range raw {
+		byDate := make(map[string][]Slot, len(ra.days))
+		for date, list := range ra.days {
+			slots := make([]Slot, 0, len(list))
+			for _, s := range list {
+				slots = append(slots, s.export(rid, date, q.PartySize, ra.token))
+			}
+			byDate[date] = slots
+		}
+		out[rid] = byDate
+	}
+	return out, nil
+}
+
 func (c *Client) fetchSlots(
 	ctx context.Context,
 	ridStr string,
@@ -42,122 +151,109 @@ func (c *Client) fetchSlots(
 		return nil, "", 0, fmt.Errorf("restaurant id %q: %w", ridStr, err)
 	}
 
-	// build payload
-	payload := map[string]any{
-		"operationName": "RestaurantsAvailability",
-		"variables": map[string]any{
-			"onlyPop":      false,
-			"forwardDays":  0,
-			"requireTimes": false,
-			"requireTypes": []string{"Standard", "Experience"},
-			"privilegedAccess": []string{
-				"VisaDiningProgram", "VisaEventsProgram", "ChaseDiningProgram",
-			},
-			"restaurantIds":  []int{rid},
-			"date":           date,
-			"time":           timePref,
-			"partySize":      party,
-			"databaseRegion": "NA",
-		},
-		"extensions": map[string]any{
-			"persistedQuery": map[string]any{
-				"version":    1,
-				"sha256Hash": "c056cbf4dbe6a95dbb5f814916415dcff0b2c93c180a456d0d4a3a3f38d0b2cc",
-			},
-		},
+	raw, err := c.fetchAvailability(ctx, AvailabilityQuery{
+		RestaurantIDs: []int{rid},
+		Date:          date,
+		Time:          timePref,
+		PartySize:     party,
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+	ra, ok := raw[rid]
+	if !ok {
+		return nil, "", 0, nil
 	}
-	body, _ := json.Marshal(payload)
+	return ra.days[date], ra.token, rid, nil
+}
 
-	const u = "https://www.opentable.ca/dapi/fe/gql?optype=query&opname=RestaurantsAvailability"
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
-	if err != nil {
-		return nil, "", 0, fmt.Errorf("build req: %w", err)
-	}
-
-	// headers
-	h := baseHeaders()
-	h.Set("accept", "*/*")
-	h.Set("content-type", "application/json")
-	h.Set("origin", "https://www.opentable.ca")
-	h.Set("ot-page-group", "rest-profile")
-	h.Set("ot-page-type", "restprofilepage")
-	h.Set("priority", "u=1, i")
-	h.Set("sec-fetch-dest", "empty")
-	h.Set("sec-fetch-mode", "cors")
-	h.Set("sec-fetch-site", "same-origin")
-	h.Set("x-csrf-token", c.csrf)
-	h.Set("x-query-timeout", "5500")
-	h[http.HeaderOrderKey] = append(
-		h[http.HeaderOrderKey],
-		"content-type", "origin", "ot-page-group", "ot-page-type",
-		"x-csrf-token", "x-query-timeout",
-	)
-	req.Header = h
+// fetchAvailability runs queryAvailability and lets the client's circuit
+// breaker see how it went.
+func (c *Client) fetchAvailability(ctx context.Context, q AvailabilityQuery) (map[int]restaurantAvail, error) {
+	out, err := c.queryAvailability(ctx, q)
+	c.breaker.record(err, time.Now())
+	return out, err
+}
 
-	// request
-	resp, err := c.tls.Do(req)
+// queryAvailability performs the RestaurantsAvailability POST and groups
+// the available slots per restaurant and calendar date.
+func (c *Client) queryAvailability(ctx context.Context, q AvailabilityQuery) (map[int]restaurantAvail, error) {
+	if len(q.RestaurantIDs) == 0 {
+		return nil, fmt.Errorf("no restaurant ids")
+	}
+	first, err := time.Parse("2006-01-02", q.Date)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("availability request: %w", err)
+		return nil, fmt.Errorf("date %q: %w", q.Date, err)
 	}
-	defer resp.Body.Close()
 
 	// decode
 	var api struct {
-		Data struct {
-			Availability []struct {
-				RestaurantID                int    `json:"restaurantId"`
-				RestaurantAvailabilityToken string `json:"restaurantAvailabilityToken"`
-				AvailabilityDays            []struct {
-					Slots []struct {
-						IsAvailable       bool     `json:"isAvailable"`
-						TimeOffsetMinutes int      `json:"timeOffsetMinutes"`
-						SlotHash          string   `json:"slotHash"`
-						PointsType        string   `json:"pointsType"`
-						PointsValue       int      `json:"pointsValue"`
-						Attributes        []string `json:"attributes"`
-						IsMandatory       bool     `json:"isMandatory"`
-					} `json:"slots"`
-				} `json:"availabilityDays"`
-			} `json:"availability"`
-		} `json:"data"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&api); err != nil {
-		return nil, "", 0, fmt.Errorf("decode: %w", err)
-	}
-	if len(api.Data.Availability) == 0 {
-		return nil, "", 0, nil
+		Availability []struct {
+			RestaurantID                int    `json:"restaurantId"`
+			RestaurantAvailabilityToken string `json:"restaurantAvailabilityToken"`
+			AvailabilityDays            []struct {
+				DayOffset int `json:"dayOffset"`
+				Slots     []struct {
+					IsAvailable       bool     `json:"isAvailable"`
+					TimeOffsetMinutes int      `json:"timeOffsetMinutes"`
+					SlotHash          string   `json:"slotHash"`
+					PointsType        string   `json:"pointsType"`
+					PointsValue       int      `json:"pointsValue"`
+					Attributes        []string `json:"attributes"`
+					IsMandatory       bool     `json:"isMandatory"`
+					Type              string   `json:"type"`
+					ExperienceIDs     []int    `json:"experienceIds"`
+				} `json:"slots"`
+			} `json:"availabilityDays"`
+		} `json:"availability"`
+	}
+	vars := map[string]any{
+		"onlyPop":      false,
+		"forwardDays":  q.ForwardDays,
+		"requireTimes": false,
+		"requireTypes": []string{"Standard", "Experience"},
+		"privilegedAccess": []string{
+			"VisaDiningProgram", "VisaEventsProgram", "ChaseDiningProgram",
+		},
+		"restaurantIds":  q.RestaurantIDs,
+		"date":           q.Date,
+		"time":           q.Time,
+		"partySize":      q.PartySize,
+		"databaseRegion": "NA",
+	}
+	if err := c.graphQL(ctx, OpRestaurantsAvailability, vars, &api); err != nil {
+		return nil, fmt.Errorf("availability: %w", err)
 	}
-
-	ra := api.Data.Availability[0]
-	token := ra.RestaurantAvailabilityToken
 
 	// compute real times from offsets
-	base, _ := time.Parse("15:04", timePref)
-	var list []slotInfo
-	for _, day := range ra.AvailabilityDays {
-		for _, s := range day.Slots {
-			if !s.IsAvailable {
-				continue
+	base, _ := time.Parse("15:04", q.Time)
+	out := make(map[int]restaurantAvail, len(api.Availability))
+	for _, ra := range api.Availability {
+		days := map[string][]slotInfo{}
+		for _, day := range ra.AvailabilityDays {
+			date := first.AddDate(0, 0, day.DayOffset).Format("2006-01-02")
+			for _, s := range day.Slots {
+				if !s.IsAvailable {
+					continue
+				}
+				tClock := base.Add(time.Minute * time.Duration(s.TimeOffsetMinutes)).Format("15:04")
+				days[date] = append(days[date], slotInfo{
+					Time:        tClock,
+					SlotHash:    s.SlotHash,
+					PointsType:  s.PointsType,
+					PointsValue: s.PointsValue,
+					Attributes:  s.Attributes,
+					IsMandatory: s.IsMandatory,
+					Experience:  strings.EqualFold(s.Type, "Experience") || len(s.ExperienceIDs) > 0,
+					Party:       q.PartySize,
+					Token:       ra.RestaurantAvailabilityToken,
+				})
 			}
-			tClock := base.Add(time.Minute * time.Duration(s.TimeOffsetMinutes)).Format("15:04")
-			list = append(list, slotInfo{
-				Time:        tClock,
-				SlotHash:    s.SlotHash,
-				PointsType:  s.PointsType,
-				PointsValue: s.PointsValue,
-				Attributes:  s.Attributes,
-				IsMandatory: s.IsMandatory,
-			})
 		}
-	}
-	return list, token, ra.RestaurantID, nil
-}
-
-func hashOfExact(list []slotInfo, pref string) string {
-	for _, s := range list {
-		if s.Time == pref {
-			return s.SlotHash
+		out[ra.RestaurantID] = restaurantAvail{
+			token: ra.RestaurantAvailabilityToken,
+			days:  days,
 		}
 	}
-	return ""
+	return out, nil
 }
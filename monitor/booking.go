@@ -0,0 +1,321 @@
+package monitor
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// maxBookAttempts is how many matched slots auto-book tries, best first,
+// before giving up (the best one is often gone by the time we lock it).
+const maxBookAttempts = 3
+
+// Diner is who a reservation is made for.
+type Diner struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`   // national number, digits only
+	Country   string `json:"country"` // ISO code of the phone number, e.g. "CA"
+	Notes     string `json:"notes,omitempty"`
+}
+
+// Validate checks the fields OpenTable insists on.
+func (d Diner) Validate() error {
+	var missing []string
+	for name, v := range map[string]string{
+		"first name": d.FirstName, "last name": d.LastName,
+		"email": d.Email, "phone": d.Phone,
+	} {
+		if strings.TrimSpace(v) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("diner profile is missing %s", strings.Join(missing, ", "))
+	}
+	if _, err := mail.ParseAddress(d.Email); err != nil {
+		return fmt.Errorf("diner email %q: %w", d.Email, err)
+	}
+	return nil
+}
+
+// orMember fills blank fields from the signed-in member's profile.
+func (d Diner) orMember(u *User) Diner {
+	if u == nil {
+		return d
+	}
+	d.FirstName = cmp.Or(d.FirstName, u.FirstName)
+	d.LastName = cmp.Or(d.LastName, u.LastName)
+	d.Email = cmp.Or(d.Email, u.Email)
+	d.Phone = cmp.Or(d.Phone, u.Phone)
+	d.Country = cmp.Or(d.Country, u.Country)
+	return d
+}
+
+// Booking is a reservation made (or, in dry-run mode, that would have
+// been made) for a slot.
+type Booking struct {
+	RestaurantID       int    `json:"restaurantId"`
+	Date               string `json:"date"`
+	Time               string `json:"time"`
+	PartySize          int    `json:"partySize"`
+	ConfirmationNumber string `json:"confirmationNumber,omitempty"`
+	ReservationID      int64  `json:"reservationId,omitempty"`
+	SecurityToken      string `json:"securityToken,omitempty"` // needed to manage the booking as a guest
+	DryRun             bool   `json:"dryRun,omitempty"`
+}
+
+// Booker books matched slots automatically. Attach it to a Watch to make
+// that watch book its best match before notifying.
+type Booker struct {
+	c      *Client
+	diner  Diner
+	dryRun bool
+}
+
+// NewBooker returns a Booker that reserves under diner. With dryRun set
+// it goes through slot selection and reports what it would book, but
+// makes no lock or reservation calls. On a signed-in client, fields left
+// blank in diner come from the member profile and bookings are made on
+// the account.
+func (c *Client) NewBooker(diner Diner, dryRun bool) (*Booker, error) {
+	diner = diner.orMember(c.User())
+	if err := diner.Validate(); err != nil {
+		return nil, err
+	}
+	return &Booker{c: c, diner: diner, dryRun: dryRun}, nil
+}
+
+// DryRun reports whether the booker only pretends to book.
+func (b *Booker) DryRun() bool { return b.dryRun }
+
+// bookBest tries the matched slots in rank order and returns the first
+// booking that succeeds. Experiences are skipped: they need prepayment.
+func (b *Booker) bookBest(ctx context.Context, hits []Slot) (*Booking, error) {
+	var errs []string
+	tried := 0
+	for _, s := range hits {
+		if tried == maxBookAttempts {
+			break
+		}
+		if s.Experience {
+			continue
+		}
+		tried++
+		bk, err := b.Book(ctx, s)
+		if err == nil {
+			return bk, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", s.Time, err))
+	}
+	if tried == 0 {
+		return nil, fmt.Errorf("no bookable slot (experiences must be booked by hand)")
+	}
+	return nil, fmt.Errorf("auto-book failed: %s", strings.Join(errs, "; "))
+}
+
+// Book locks s and reserves it for the booker's diner.
+func (b *Booker) Book(ctx context.Context, s Slot) (*Booking, error) {
+	bk := &Booking{
+		RestaurantID: s.RestaurantID,
+		Date:         s.Date,
+		Time:         s.Time,
+		PartySize:    s.PartySize,
+		DryRun:       b.dryRun,
+	}
+	if b.dryRun {
+		fmt.Fprintf(Output, "🧪  [dry run] would book %d on %s at %s for %d (%s %s)\n",
+			s.RestaurantID, s.Date, s.Time, s.PartySize, b.diner.FirstName, b.diner.LastName)
+		return bk, nil
+	}
+
+	lockID, err := b.c.LockSlot(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	res, err := b.c.makeReservation(ctx, s, lockID, b.diner, nil)
+	if err != nil {
+		return nil, err
+	}
+	bk.ConfirmationNumber = res.ConfirmationNumber
+	bk.ReservationID = res.ReservationID
+	bk.SecurityToken = res.SecurityToken
+	fmt.Fprintf(Output, "📗  Booked %d on %s at %s for %d — confirmation %s\n",
+		s.RestaurantID, s.Date, s.Time, s.PartySize, bk.ConfirmationNumber)
+	return bk, nil
+}
+
+// LockSlot holds s for a few minutes so the reservation can be completed,
+// returning the lock ID make-reservation needs.
+func (c *Client) LockSlot(ctx context.Context, s Slot) (int64, error) {
+	vars := map[string]any{
+		"slotLockInput": map[string]any{
+			"restaurantId":        s.RestaurantID,
+			"seatingOption":       "DEFAULT",
+			"reservationDateTime": s.Date + "T" + s.Time,
+			"partySize":           s.PartySize,
+			"databaseRegion":      "NA",
+			"slotHash":            s.SlotHash,
+			"reservationType":     "STANDARD",
+			"diningAreaId":        1,
+		},
+	}
+	var out struct {
+		LockSlot struct {
+			Success  bool `json:"success"`
+			SlotLock struct {
+				SlotLockID int64 `json:"slotLockId"`
+			} `json:"slotLock"`
+			SlotLockErrors []struct {
+				Message string `json:"message"`
+			} `json:"slotLockErrors"`
+		} `json:"lockSlot"`
+	}
+	if err := c.graphQL(ctx, OpSlotLock, vars, &out); err != nil {
+		return 0, fmt.Errorf("lock slot: %w", err)
+	}
+	ls := out.LockSlot
+	if !ls.Success || ls.SlotLock.SlotLockID == 0 {
+		msg := "slot no longer available"
+		if len(ls.SlotLockErrors) > 0 && ls.SlotLockErrors[0].Message != "" {
+			msg = ls.SlotLockErrors[0].Message
+		}
+		return 0, fmt.Errorf("lock slot: %s", msg)
+	}
+	return ls.SlotLock.SlotLockID, nil
+}
+
+// reservation is the part of the make-reservation response we keep.
+type reservation struct {
+	Success            bool   `json:"success"`
+	ReservationID      int64  `json:"reservationId"`
+	ConfirmationNumber string `json:"confirmationNumber"`
+	SecurityToken      string `json:"securityToken"`
+	ErrorCode          string `json:"errorCode"`
+	ErrorMessage       string `json:"errorMessage"`
+}
+
+// makeReservation completes a locked slot for diner. With modify set it
+// moves that existing reservation to s instead of making a new one.
+func (c *Client) makeReservation(ctx context.Context, s Slot, lockID int64, d Diner, modify *Reservation) (reservation, error) {
+	country := d.Country
+	if country == "" {
+		country = "CA"
+	}
+	body := map[string]any{
+		"restaurantId":          s.RestaurantID,
+		"slotAvailabilityToken": s.Token,
+		"slotHash":              s.SlotHash,
+		"isModify":              modify != nil,
+		"reservationDateTime":   s.Date + "T" + s.Time,
+		"partySize":             s.PartySize,
+		"firstName":             d.FirstName,
+		"lastName":              d.LastName,
+		"email":                 d.Email,
+		"country":               country,
+		"phoneNumber":           d.Phone,
+		"phoneNumberCountryId":  country,
+		"specialRequests":       d.Notes,
+		"slotLockId":            lockID,
+		"reservationType":       "Standard",
+		"reservationAttribute":  firstOr(s.Attributes, "default"),
+		"pointsType":            s.PointsType,
+		"points":                s.PointsValue,
+		"diningAreaId":          1,
+		"optInEmailRestaurant":  false,
+	}
+	if u := c.User(); u != nil {
+		body["gpid"] = u.ID
+	}
+	if modify != nil {
+		body["reservationId"] = modify.ID
+		body["confirmationNumber"] = modify.ConfirmationNumber
+		body["securityToken"] = modify.SecurityToken
+	}
+	var res reservation
+	if err := c.postJSON(ctx, BaseURL+"/dapi/booking/make-reservation", "booking", "booking-details", body, &res); err != nil {
+		return res, fmt.Errorf("make reservation: %w", err)
+	}
+	if !res.Success || res.ConfirmationNumber == "" {
+		msg := res.ErrorMessage
+		if msg == "" {
+			msg = res.ErrorCode
+		}
+		if msg == "" {
+			msg = "rejected without a reason"
+		}
+		return res, fmt.Errorf("make reservation: %s", msg)
+	}
+	return res, nil
+}
+
+// postJSON POSTs payload to u with the site's API headers and decodes
+// the JSON response into out.
+func (c *Client) postJSON(ctx context.Context, u, pageGroup, pageType string, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	return c.doJSON(ctx, http.MethodPost, u, pageGroup, pageType, body, out)
+}
+
+// getJSON GETs u with the site's API headers and decodes the JSON
+// response into out.
+func (c *Client) getJSON(ctx context.Context, u, pageGroup, pageType string, out any) error {
+	return c.doJSON(ctx, http.MethodGet, u, pageGroup, pageType, nil, out)
+}
+
+// doJSON sends one API request. Non-2xx responses become errors carrying
+// the start of the body.
+func (c *Client) doJSON(ctx context.Context, method, u, pageGroup, pageType string, body []byte, out any) error {
+	res, err := c.send(ctx, method, u, body, pageGroup, pageType, "10000")
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(res.body, out); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return nil
+}
+
+// snippet shortens a response body for error messages.
+func snippet(b []byte) string {
+	s := strings.TrimSpace(string(b))
+	if len(s) > 200 {
+		s = s[:200] + "…"
+	}
+	return s
+}
+
+func firstOr(list []string, def string) string {
+	if len(list) > 0 && list[0] != "" {
+		return list[0]
+	}
+	return def
+}
+
+// autoBook books one of hits if w has a Booker, or moves the upgrade
+// watch's reservation to it. It is called before the match is announced
+// so the confirmation can go out with it. ctx is the poll's, so stopping
+// the watch abandons the booking and it goes out through the watch's proxy.
+func autoBook(ctx context.Context, w Watch, hits []Slot) (*Booking, error) {
+	if w.Booker == nil || len(hits) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 45*time.Second)
+	defer cancel()
+	if w.Upgrade != nil {
+		return w.Booker.moveBest(ctx, *w.Upgrade.Reservation, hits)
+	}
+	return w.Booker.bookBest(ctx, hits)
+}
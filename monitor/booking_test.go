@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tls_client "github.com/bogdanfinn/tls-client"
+)
+
+// fakeSite stands in for OpenTable: it answers slot locks and
+// make-reservation calls and records every request it gets.
+type fakeSite struct {
+	*httptest.Server
+	taken map[string]bool // slot hashes whose lock fails
+
+	mu    sync.Mutex
+	calls []string       // "lock <hash>" or "reserve <lock id>"
+	body  map[string]any // last make-reservation body
+}
+
+func newFakeSite(t *testing.T) *fakeSite {
+	f := &fakeSite{taken: map[string]bool{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeSite) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-csrf-token") != "csrf-token" {
+		http.Error(w, "bad csrf", http.StatusForbidden)
+		return
+	}
+	var body map[string]any
+	raw, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(raw, &body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/dapi/fe/gql" && r.URL.Query().Get("opname") == OpSlotLock:
+		in := body["variables"].(map[string]any)["slotLockInput"].(map[string]any)
+		hash, _ := in["slotHash"].(string)
+		f.calls = append(f.calls, "lock "+hash)
+		if f.taken[hash] {
+			io.WriteString(w, `{"data":{"lockSlot":{"success":false,"slotLockErrors":[{"message":"slot taken"}]}}}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"lockSlot":{"success":true,"slotLock":{"slotLockId":4242}}}}`)
+	case r.URL.Path == "/dapi/booking/make-reservation":
+		f.calls = append(f.calls, "reserve "+jsonString(body["slotLockId"]))
+		f.body = body
+		io.WriteString(w, `{"success":true,"reservationId":99,"confirmationNumber":"C-1234","securityToken":"sec"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeSite) log() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.calls, ", ")
+}
+
+func jsonString(v any) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// newTestClient returns a client that sends everything to site.
+func newTestClient(t *testing.T, site *httptest.Server) *Client {
+	t.Helper()
+	base, out := BaseURL, Output
+	BaseURL, Output = site.URL, io.Discard
+	t.Cleanup(func() { BaseURL, Output = base, out })
+
+	tls, err := newTLSClient(tls_client.NewCookieJar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{tls: tls, csrf: "csrf-token"}
+}
+
+var testDiner = Diner{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Phone: "4165550100", Country: "CA"}
+
+func testSlot(hash, at string) Slot {
+	return Slot{RestaurantID: 1234, Date: "2026-11-02", Time: at, PartySize: 2, SlotHash: hash, Token: "tok-" + hash}
+}
+
+func TestBookLocksThenReserves(t *testing.T) {
+	site := newFakeSite(t)
+	b, err := newTestClient(t, site.Server).NewBooker(testDiner, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bk, err := b.Book(context.Background(), testSlot("h1", "19:30"))
+	if err != nil {
+		t.Fatalf("Book: %v", err)
+	}
+	if got, want := site.log(), "lock h1, reserve 4242"; got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+	if bk.ConfirmationNumber != "C-1234" || bk.ReservationID != 99 || bk.SecurityToken != "sec" || bk.DryRun {
+		t.Errorf("booking = %+v", bk)
+	}
+	for k, want := range map[string]any{
+		"slotHash": "h1", "slotAvailabilityToken": "tok-h1", "reservationDateTime": "2026-11-02T19:30",
+		"firstName": "Ada", "email": "ada@example.com", "phoneNumber": "4165550100", "isModify": false,
+	} {
+		if site.body[k] != want {
+			t.Errorf("make-reservation %s = %v, want %v", k, site.body[k], want)
+		}
+	}
+}
+
+func TestBookDryRunBooksNothing(t *testing.T) {
+	site := newFakeSite(t)
+	b, err := newTestClient(t, site.Server).NewBooker(testDiner, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bk, err := b.bookBest(context.Background(), []Slot{testSlot("h1", "19:30")})
+	if err != nil {
+		t.Fatalf("bookBest: %v", err)
+	}
+	if !bk.DryRun || bk.ConfirmationNumber != "" || bk.Time != "19:30" {
+		t.Errorf("booking = %+v, want an unconfirmed dry run of 19:30", bk)
+	}
+	if got := site.log(); got != "" {
+		t.Errorf("dry run sent %s", got)
+	}
+}
+
+func TestBookBestFallsBackToRunnerUp(t *testing.T) {
+	site := newFakeSite(t)
+	site.taken["h1"] = true
+	b, err := newTestClient(t, site.Server).NewBooker(testDiner, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := testSlot("x", "19:00")
+	exp.Experience = true
+	bk, err := b.bookBest(context.Background(), []Slot{exp, testSlot("h1", "19:30"), testSlot("h2", "20:00")})
+	if err != nil {
+		t.Fatalf("bookBest: %v", err)
+	}
+	if bk.Time != "20:00" || bk.ConfirmationNumber != "C-1234" {
+		t.Errorf("booking = %+v, want the 20:00 runner-up", bk)
+	}
+	if got, want := site.log(), "lock h1, lock h2, reserve 4242"; got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestBookBestGivesUp(t *testing.T) {
+	site := newFakeSite(t)
+	site.taken["h1"], site.taken["h2"] = true, true
+	b, err := newTestClient(t, site.Server).NewBooker(testDiner, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.bookBest(context.Background(), []Slot{testSlot("h1", "19:30"), testSlot("h2", "20:00")})
+	if err == nil || !strings.Contains(err.Error(), "19:30: lock slot: slot taken") || !strings.Contains(err.Error(), "20:00") {
+		t.Fatalf("err = %v, want both failures", err)
+	}
+	if got := site.log(); strings.Contains(got, "reserve") {
+		t.Errorf("reserved after failed locks: %s", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	http "github.com/bogdanfinn/fhttp"
@@ -17,70 +19,98 @@ import (
 // Client bundles the TLS client, CSRF token and user coordinates
 // so callers don't repeat expensive setup for every request.
 type Client struct {
-	tls  tls_client.HttpClient
-	csrf string
-	lat  float64
-	lon  float64
+	lat float64
+	lon float64
+
+	user    *User    // signed-in member; nil when anonymous; guarded by mu
+	session *session // where the signed-in cookies live; nil when anonymous
+
+	breaker circuit // pauses polling while the site keeps blocking us
+
+	proxies *ProxyPool // where requests go out through; nil = directly
+
+	// mu guards the connection state that recovering from a rejected
+	// request replaces while other requests may be in flight, what the
+	// client learned about query hashes, and the session's user and
+	// checked time.
+	mu        sync.Mutex
+	tls       tls_client.HttpClient            // direct connection; its jar holds the shared cookies
+	conns     map[*proxy]tls_client.HttpClient // one connection per proxy of the pool
+	csrf      string
+	proxy     *proxy    // the pool's proxy used last
+	recovered time.Time // last time a rejected request made us recover
+
+	learned    map[string]Operation // query hashes found at runtime, over the registry
+	discovered time.Time            // last time the site's scripts were searched for query hashes
 }
 
 // New spins up a ready-to-use *Client in three quick steps:
 //  1. create the TLS fingerprinted HTTP client
 //  2. fetch a CSRF token
 //  3. look up the user's latitude / longitude
-func New(ctx context.Context) (*Client, error) {
-	tls, err := newTLSClient()
+//
+// When pool is non-nil every request to the site goes out through it,
+// the first one included.
+func New(ctx context.Context, pool *ProxyPool) (*Client, error) {
+	tls, err := newTLSClient(cookieJar)
 	if err != nil {
 		return nil, fmt.Errorf("tls-client: %w", err)
 	}
-	csrf, err := fetchCSRFToken(tls)
-	if err != nil {
-		return nil, fmt.Errorf("csrf: %w", err)
+	c := &Client{tls: tls, proxies: pool}
+	if err := c.refreshCSRF(ctx); err != nil {
+		return nil, err
 	}
 	coords, err := geo.GetCoordinates()
 	if err != nil {
 		return nil, fmt.Errorf("geo lookup: %w", err)
 	}
-
-	return &Client{
-		tls:  tls,
-		csrf: csrf,
-		lat:  coords.Lat,
-		lon:  coords.Lon,
-	}, nil
+	c.lat, c.lon = coords.Lat, coords.Lon
+	return c, nil
 }
 
 // newTLSClient returns an HTTP/2-capable client with a realistic TLS
-// fingerprint and shared cookie jar.
-func newTLSClient() (tls_client.HttpClient, error) {
-	opts := []tls_client.HttpClientOption{
+// fingerprint, the given cookie jar and any extra options.
+func newTLSClient(jar tls_client.CookieJar, extra ...tls_client.HttpClientOption) (tls_client.HttpClient, error) {
+	opts := append([]tls_client.HttpClientOption{
 		tls_client.WithTimeoutSeconds(30),
 		tls_client.WithClientProfile(profiles.Chrome_133),
-		tls_client.WithCookieJar(cookieJar),
+		tls_client.WithCookieJar(jar),
 		tls_client.WithNotFollowRedirects(),
-	}
+	}, extra...)
 	return tls_client.NewHttpClient(tls_client.NewNoopLogger(), opts...)
 }
 
+// conn returns the TLS client requests currently go out on.
+func (c *Client) conn() tls_client.HttpClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tls
+}
+
+// token returns the current CSRF token.
+func (c *Client) token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.csrf
+}
+
 // fetchCSRFToken requests the OpenTable homepage and extracts the
 // windowVariables.__CSRF_TOKEN__ value from the embedded <script>.
-func fetchCSRFToken(client tls_client.HttpClient) (string, error) {
-	req, err := http.NewRequest(http.MethodGet, "https://www.opentable.ca/", nil)
+func (c *Client) fetchCSRFToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, BaseURL+"/", nil)
 	if err != nil {
 		return "", fmt.Errorf("build req: %w", err)
 	}
 	req.Header = baseHeaders()
 
-	resp, err := client.Do(req)
+	res, err := c.fetch(req)
 	if err != nil {
 		return "", fmt.Errorf("request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	var buf bytes.Buffer
-	if _, err := buf.ReadFrom(resp.Body); err != nil {
-		return "", fmt.Errorf("read body: %w", err)
+	if res.status == http.StatusForbidden || res.status == http.StatusTooManyRequests {
+		return "", &StatusError{Code: res.status, Body: snippet(res.body)}
 	}
-	return extractCSRF(buf.Bytes())
+	return extractCSRF(res.body)
 }
 
 // extractCSRF parses the primary-window-vars <script> tag.
@@ -0,0 +1,187 @@
+package monitor
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRangeDates caps how many concrete dates one DateSpec may expand to.
+const maxRangeDates = 62
+
+// DateSpec is a set of dates a watch covers: explicit dates, a From–To
+// range or a rolling window of the next Next days, optionally limited to
+// some weekdays. It is expanded into concrete dates as time passes, so
+// past dates drop out and rolling windows pick up new ones.
+type DateSpec struct {
+	Dates    []string       `json:"dates,omitempty"` // YYYY-MM-DD
+	From     string         `json:"from,omitempty"`  // YYYY-MM-DD, inclusive
+	To       string         `json:"to,omitempty"`    // YYYY-MM-DD, inclusive
+	Next     int            `json:"next,omitempty"`  // days from today, inclusive of today
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDateSpec reads the compact form used by the CLI, the watch file
+// and the TUI. Space-separated parts may be combined:
+//
+//	2025-07-04                single date (a comma list is fine too)
+//	2025-11-01..2025-11-15    inclusive range
+//	next 6w / next 10d        rolling window from today
+//	fri,sat                   only these weekdays (also "weekends")
+//
+// e.g. "fri,sat next 6w" or "mon 2025-11-01..2025-11-30".
+func ParseDateSpec(s string) (DateSpec, error) {
+	var spec DateSpec
+	fields := strings.Fields(strings.ToLower(s))
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case f == "next":
+			if i+1 == len(fields) {
+				return spec, fmt.Errorf("%q: \"next\" needs a length like 6w or 14d", s)
+			}
+			i++
+			n, err := parseSpan(fields[i])
+			if err != nil {
+				return spec, fmt.Errorf("%q: %w", s, err)
+			}
+			spec.Next = n
+		case strings.Contains(f, ".."):
+			from, to, _ := strings.Cut(f, "..")
+			spec.From, spec.To = from, to
+		case f == "weekends":
+			spec.Weekdays = append(spec.Weekdays, time.Saturday, time.Sunday)
+		case f == "weekdays":
+			spec.Weekdays = append(spec.Weekdays, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+		default:
+			for _, part := range strings.Split(f, ",") {
+				if part == "" {
+					continue
+				}
+				if wd, ok := weekdayNames[part[:min(3, len(part))]]; ok && !strings.ContainsAny(part, "0123456789") {
+					spec.Weekdays = append(spec.Weekdays, wd)
+					continue
+				}
+				spec.Dates = append(spec.Dates, part)
+			}
+		}
+	}
+	return spec, spec.validate()
+}
+
+// parseSpan reads "6w" or "14d" (a bare number means days).
+func parseSpan(s string) (int, error) {
+	unit := 1
+	switch {
+	case strings.HasSuffix(s, "w"):
+		unit, s = 7, strings.TrimSuffix(s, "w")
+	case strings.HasSuffix(s, "d"):
+		s = strings.TrimSuffix(s, "d")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("bad length %q", s)
+	}
+	return n * unit, nil
+}
+
+// validate checks dates are well formed and the spec says something.
+func (d DateSpec) validate() error {
+	for _, v := range append(slices.Clone(d.Dates), d.From, d.To) {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return fmt.Errorf("date %q: want YYYY-MM-DD", v)
+		}
+	}
+	if (d.From == "") != (d.To == "") {
+		return fmt.Errorf("a range needs both ends")
+	}
+	if d.From > d.To {
+		return fmt.Errorf("range %s..%s ends before it starts", d.From, d.To)
+	}
+	if len(d.Dates) == 0 && d.From == "" && d.Next == 0 {
+		if len(d.Weekdays) > 0 {
+			return fmt.Errorf("weekdays need a range or \"next N\" to apply to")
+		}
+		return fmt.Errorf("no dates given")
+	}
+	return nil
+}
+
+// IsSingle reports whether the spec is exactly one fixed date.
+func (d DateSpec) IsSingle() bool {
+	return len(d.Dates) == 1 && d.From == "" && d.Next == 0 && len(d.Weekdays) == 0
+}
+
+// Expand returns the concrete dates the spec covers from today on,
+// sorted and de-duplicated. Past dates are never included.
+func (d DateSpec) Expand(today time.Time) []string {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	first := today.Format("2006-01-02")
+
+	keep := func(day time.Time) bool {
+		return len(d.Weekdays) == 0 || slices.Contains(d.Weekdays, day.Weekday())
+	}
+	var out []string
+	add := func(day time.Time) {
+		if s := day.Format("2006-01-02"); s >= first && keep(day) && !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+
+	for _, v := range d.Dates {
+		if day, err := time.Parse("2006-01-02", v); err == nil {
+			add(day)
+		}
+	}
+	if d.From != "" {
+		from, _ := time.Parse("2006-01-02", d.From)
+		to, _ := time.Parse("2006-01-02", d.To)
+		if from.Before(today) {
+			from = today
+		}
+		for day := from; !day.After(to) && len(out) < maxRangeDates; day = day.AddDate(0, 0, 1) {
+			add(day)
+		}
+	}
+	for i := 0; i < d.Next && len(out) < maxRangeDates; i++ {
+		add(today.AddDate(0, 0, i))
+	}
+
+	slices.Sort(out)
+	if len(out) > maxRangeDates {
+		out = out[:maxRangeDates]
+	}
+	return out
+}
+
+// String renders the spec in the form ParseDateSpec reads.
+func (d DateSpec) String() string {
+	var parts []string
+	if len(d.Weekdays) > 0 {
+		names := make([]string, len(d.Weekdays))
+		for i, wd := range d.Weekdays {
+			names[i] = strings.ToLower(wd.String()[:3])
+		}
+		parts = append(parts, strings.Join(names, ","))
+	}
+	if len(d.Dates) > 0 {
+		parts = append(parts, strings.Join(d.Dates, ","))
+	}
+	if d.From != "" {
+		parts = append(parts, d.From+".."+d.To)
+	}
+	if d.Next > 0 {
+		parts = append(parts, fmt.Sprintf("next %dd", d.Next))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Why OpenTable refused a request. Client methods wrap these, so callers
+// can tell them apart with errors.Is.
+var (
+	ErrBlocked                = errors.New("blocked by OpenTable's bot protection")
+	ErrRateLimited            = errors.New("rate limited by OpenTable")
+	ErrGraphQL                = errors.New("OpenTable GraphQL error")
+	ErrPersistedQueryNotFound = errors.New("persisted query not found")
+)
+
+// StatusError is an unexpected HTTP response from the site. It matches
+// ErrBlocked for a 403 or a bot-challenge page and ErrRateLimited for a
+// 429.
+type StatusError struct {
+	Code      int
+	Body      string // start of the response body
+	Challenge bool   // a bot-challenge page instead of the API's answer
+}
+
+func (e *StatusError) Error() string {
+	if e.Challenge {
+		return fmt.Sprintf("status %d: bot challenge page", e.Code)
+	}
+	return fmt.Sprintf("status %d: %s", e.Code, e.Body)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrBlocked:
+		return e.Challenge || e.Code == 403
+	case ErrRateLimited:
+		return e.Code == 429
+	}
+	return false
+}
+
+// GraphQLError holds the "errors" array of a GraphQL response. It
+// matches ErrGraphQL, and ErrPersistedQueryNotFound when the server no
+// longer knows the query's hash.
+type GraphQLError struct {
+	Operation string
+	Messages  []string
+	Codes     []string // extensions.code of each error, where given
+}
+
+func (e *GraphQLError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Operation, strings.Join(e.Messages, "; "))
+}
+
+func (e *GraphQLError) Is(target error) bool {
+	switch target {
+	case ErrGraphQL:
+		return true
+	case ErrPersistedQueryNotFound:
+		return slices.Contains(e.Codes, "PERSISTED_QUERY_NOT_FOUND") ||
+			slices.Contains(e.Messages, "PersistedQueryNotFound")
+	}
+	return false
+}
+
+// checkResponse turns a challenge page or a non-2xx status into a
+// StatusError.
+func checkResponse(res apiResponse) error {
+	if _, challenged := rejected(res); challenged {
+		return &StatusError{Code: res.status, Challenge: true}
+	}
+	if res.status < 200 || res.status >= 300 {
+		return &StatusError{Code: res.status, Body: snippet(res.body)}
+	}
+	return nil
+}
+
+// decodeGraphQL checks the response to the GraphQL operation op and
+// decodes its "data" into data. A non-empty "errors" array is an error
+// even when some data came back, so a failed query can never pass for
+// an empty result.
+func decodeGraphQL(op string, res apiResponse, data any) error {
+	var env struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string `json:"message"`
+			Extensions struct {
+				Code string `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	// Apollo reports an unknown hash with a 200 or a 400, so look for
+	// GraphQL errors before judging the status
+	jsonErr := json.Unmarshal(res.body, &env)
+	if jsonErr == nil && len(env.Errors) > 0 {
+		ge := &GraphQLError{Operation: op}
+		for _, e := range env.Errors {
+			ge.Messages = append(ge.Messages, e.Message)
+			if e.Extensions.Code != "" {
+				ge.Codes = append(ge.Codes, e.Extensions.Code)
+			}
+		}
+		return ge
+	}
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+	if jsonErr != nil {
+		return fmt.Errorf("decode: %w", jsonErr)
+	}
+	if len(env.Data) == 0 || string(env.Data) == "null" {
+		return fmt.Errorf("%s: response has no data", op)
+	}
+	if err := json.Unmarshal(env.Data, data); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return nil
+}
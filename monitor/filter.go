@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Inventory restricts a watch to standard tables or to experiences
+// (tasting menus, prepaid events and other ticketed slots).
+type Inventory string
+
+const (
+	InventoryAny        Inventory = ""
+	InventoryStandard   Inventory = "standard"
+	InventoryExperience Inventory = "experience"
+)
+
+// SlotFilter decides which slots a watch cares about at all. Slots it
+// rejects are dropped before matching, diffing and notifications, so a
+// watch never alerts about seating it doesn't want. The zero value
+// accepts everything.
+type SlotFilter struct {
+	Include   []string  `json:"include,omitempty"`   // slot needs at least one of these attributes
+	Exclude   []string  `json:"exclude,omitempty"`   // slot must have none of these attributes
+	Inventory Inventory `json:"inventory,omitempty"` // standard, experience or any
+}
+
+// IsZero reports whether f lets every slot through.
+func (f SlotFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && f.Inventory == InventoryAny
+}
+
+func (f SlotFilter) equal(o SlotFilter) bool {
+	return slices.Equal(f.Include, o.Include) && slices.Equal(f.Exclude, o.Exclude) &&
+		f.Inventory == o.Inventory
+}
+
+// Validate checks the inventory and that no attribute is both included
+// and excluded, spellings compared as the filter compares them. Errors
+// are *FieldError.
+func (f SlotFilter) Validate() error {
+	switch f.Inventory {
+	case InventoryAny, InventoryStandard, InventoryExperience:
+	default:
+		return inField("inventory", fmt.Errorf("unknown inventory %q (want standard or experience)", f.Inventory))
+	}
+	for _, a := range f.Include {
+		if slices.ContainsFunc(f.Exclude, func(b string) bool { return attrKey(a) == attrKey(b) }) {
+			return inField("exclude", fmt.Errorf("attribute %q is both included and excluded", a))
+		}
+	}
+	return nil
+}
+
+// String describes the filter for terminal output.
+func (f SlotFilter) String() string {
+	var parts []string
+	if len(f.Include) > 0 {
+		parts = append(parts, "only "+strings.Join(f.Include, "/"))
+	}
+	if len(f.Exclude) > 0 {
+		parts = append(parts, "no "+strings.Join(f.Exclude, "/"))
+	}
+	if f.Inventory != InventoryAny {
+		parts = append(parts, string(f.Inventory)+" only")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// allows reports whether s passes the filter.
+func (f SlotFilter) allows(s slotInfo) bool {
+	switch f.Inventory {
+	case InventoryStandard:
+		if s.Experience {
+			return false
+		}
+	case InventoryExperience:
+		if !s.Experience {
+			return false
+		}
+	}
+	has := func(want string) bool {
+		return slices.ContainsFunc(s.Attributes, func(a string) bool { return attrKey(a) == attrKey(want) })
+	}
+	if slices.ContainsFunc(f.Exclude, has) {
+		return false
+	}
+	return len(f.Include) == 0 || slices.ContainsFunc(f.Include, has)
+}
+
+// apply returns the slots of list that pass the filter.
+func (f SlotFilter) apply(list []slotInfo) []slotInfo {
+	if f.IsZero() {
+		return list
+	}
+	out := make([]slotInfo, 0, len(list))
+	for _, s := range list {
+		if f.allows(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// attrKey normalises attribute spellings so "high-top", "High Top" and
+// "highTop" compare equal.
+func attrKey(a string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "", " ", "").Replace(a))
+}
@@ -0,0 +1,222 @@
+package monitor
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for a RetryPolicy and the client's circuit breaker.
+const (
+	defaultBackoff     = 15 * time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+	defaultAlertAfter  = 5
+	blockedBackoffRate = 4 // blocked polls back off this much harder
+
+	breakerStrikes     = 3 // blocked polls in a row that open the circuit
+	breakerCooldown    = 5 * time.Minute
+	maxBreakerCooldown = time.Hour
+)
+
+// ErrorKind classifies a failed request so polling knows how to react.
+type ErrorKind string
+
+const (
+	ErrorNetwork ErrorKind = "network" // timeout, reset, DNS
+	ErrorStatus  ErrorKind = "http"    // an unexpected HTTP status (usually a 5xx) or GraphQL errors
+	ErrorDecode  ErrorKind = "decode"  // a body that isn't the JSON expected
+	ErrorBlocked ErrorKind = "blocked" // ErrBlocked or ErrRateLimited: the site is pushing back
+	ErrorFatal   ErrorKind = "fatal"   // bad input; retrying can't help
+	ErrorOther   ErrorKind = "other"
+)
+
+// Classify sorts err into an ErrorKind. Only ErrorFatal stops a watch
+// outright; everything else is retried with backoff.
+func Classify(err error) ErrorKind {
+	var (
+		status *StatusError
+		netErr net.Error
+		syntax *json.SyntaxError
+		typ    *json.UnmarshalTypeError
+		num    *strconv.NumError
+		parse  *time.ParseError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrBlocked), errors.Is(err, ErrRateLimited):
+		return ErrorBlocked
+	case errors.As(err, &status), errors.Is(err, ErrGraphQL):
+		return ErrorStatus
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded):
+		return ErrorNetwork
+	case errors.As(err, &syntax), errors.As(err, &typ):
+		return ErrorDecode
+	case errors.As(err, &num), errors.As(err, &parse):
+		return ErrorFatal
+	}
+	return ErrorOther
+}
+
+// RetryPolicy decides how a watch rides out failed polls. Zero fields
+// take the defaults.
+type RetryPolicy struct {
+	Backoff     time.Duration `json:"backoff,omitempty"`     // wait after the first failure, doubling after each; default 15s
+	MaxBackoff  time.Duration `json:"maxBackoff,omitempty"`  // longest wait between retries; default 10m
+	AlertAfter  int           `json:"alertAfter,omitempty"`  // failures in a row before the watch counts as degraded; default 5
+	GiveUpAfter int           `json:"giveUpAfter,omitempty"` // failures in a row before the watch fails; 0 = never
+}
+
+// Validate checks no setting is negative.
+func (p RetryPolicy) Validate() error {
+	if p.Backoff < 0 || p.MaxBackoff < 0 || p.AlertAfter < 0 || p.GiveUpAfter < 0 {
+		return fmt.Errorf("retry settings must not be negative")
+	}
+	return nil
+}
+
+// backoff is the wait after the n-th failure in a row.
+func (p RetryPolicy) backoff(kind ErrorKind, n int) time.Duration {
+	d, ceiling := cmp.Or(p.Backoff, defaultBackoff), cmp.Or(p.MaxBackoff, defaultMaxBackoff)
+	if kind == ErrorBlocked {
+		d *= blockedBackoffRate
+	}
+	for i := 1; i < n && d < ceiling; i++ {
+		d *= 2
+	}
+	return max(min(d, ceiling), minPollGap)
+}
+
+// Health reports a watch becoming degraded, or recovering from it.
+type Health struct {
+	Degraded bool      // false = polling works again
+	Failures int       // failed polls in the run
+	Kind     ErrorKind // class of the last failure; degraded only
+	Since    time.Time // first failure of the run
+	Err      error     // last failure; degraded only
+}
+
+func (h Health) String() string {
+	if h.Degraded {
+		return fmt.Sprintf("%d polls in a row failed since %s (%s): %v",
+			h.Failures, h.Since.Format("Jan 2 15:04"), h.Kind, h.Err)
+	}
+	return fmt.Sprintf("polling again after %d failed polls over %s",
+		h.Failures, time.Since(h.Since).Round(time.Second))
+}
+
+// health is a watch's current run of failed polls.
+type health struct {
+	failures int
+	since    time.Time
+	degraded bool
+}
+
+// fail records a failed poll. It returns how long to wait before trying
+// again, the Health to report (nil when nothing changed) and whether the
+// watch should give up.
+func (h *health) fail(p RetryPolicy, err error, now time.Time) (time.Duration, *Health, bool) {
+	kind := Classify(err)
+	if h.failures == 0 {
+		h.since = now
+	}
+	h.failures++
+	if kind == ErrorFatal || p.GiveUpAfter > 0 && h.failures >= p.GiveUpAfter {
+		return 0, nil, true
+	}
+	var ev *Health
+	if !h.degraded && h.failures >= cmp.Or(p.AlertAfter, defaultAlertAfter) {
+		h.degraded = true
+		ev = &Health{Degraded: true, Failures: h.failures, Kind: kind, Since: h.since, Err: err}
+	}
+	return p.backoff(kind, h.failures), ev, false
+}
+
+// ok records a successful poll, returning the recovery to report if the
+// watch was degraded.
+func (h *health) ok() *Health {
+	var ev *Health
+	if h.degraded {
+		ev = &Health{Failures: h.failures, Since: h.since}
+	}
+	*h = health{}
+	return ev
+}
+
+// reportFailure prints a failed poll that will be retried and hands a
+// change of health to the watch.
+func reportFailure(w Watch, failures int, wait time.Duration, err error, ev *Health) {
+	fmt.Fprintf(Output, "⚠️  [%s] poll failed (%s, %d in a row), retrying in %s: %v\n",
+		w.ID, Classify(err), failures, wait.Round(time.Second), err)
+	reportHealth(w, ev)
+}
+
+// reportHealth announces a watch becoming degraded or recovering.
+func reportHealth(w Watch, ev *Health) {
+	if ev == nil {
+		return
+	}
+	if ev.Degraded {
+		fmt.Fprintf(Output, "🩺  [%s] degraded: %s\n", w.ID, ev)
+	} else {
+		fmt.Fprintf(Output, "🩺  [%s] recovered: %s\n", w.ID, ev)
+	}
+	if w.OnEvent != nil {
+		w.OnEvent(WatchEvent{Watch: w, Health: ev})
+	}
+}
+
+// circuit pauses all polling on a client once the site blocks it again
+// and again, rather than letting every watch keep knocking on its own
+// schedule. After the pause one poll probes: success closes the circuit,
+// another block reopens it for twice as long. It is safe for concurrent
+// use.
+type circuit struct {
+	mu        sync.Mutex
+	strikes   int // blocked polls in a row
+	trips     int // openings without a success in between
+	openUntil time.Time
+	probing   bool
+}
+
+// record feeds the outcome of one availability request to the breaker.
+func (b *circuit) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch Classify(err) {
+	case "":
+		b.strikes, b.trips, b.openUntil, b.probing = 0, 0, time.Time{}, false
+		return
+	case ErrorBlocked:
+		b.strikes++
+	default:
+		return
+	}
+	if b.strikes < breakerStrikes && !b.probing {
+		return
+	}
+	cooldown := breakerCooldown
+	for i := 0; i < b.trips && cooldown < maxBreakerCooldown; i++ {
+		cooldown *= 2
+	}
+	cooldown = min(cooldown, maxBreakerCooldown)
+	b.trips++
+	b.strikes, b.probing = 0, true
+	b.openUntil = now.Add(cooldown)
+	fmt.Fprintf(Output, "🚧  Blocked by the site — pausing all polling for %s\n", cooldown)
+}
+
+// wait is how long polling must stay paused.
+func (b *circuit) wait(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.openUntil.Sub(now), 0)
+}
@@ -0,0 +1,208 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// MatchPolicy says what a watch does once a slot matches.
+type MatchPolicy string
+
+const (
+	PolicyStop     MatchPolicy = "stop"     // report the best match and finish (default)
+	PolicyContinue MatchPolicy = "continue" // keep polling, report each better match
+)
+
+// TimeMatch describes which slot times count as a hit for a watch. The
+// zero value matches the watch's preferred time exactly.
+//
+// Times listed in Preferred rank in order, first is best; Tolerance widens
+// each of them by ± that much. Any other time inside Window still matches
+// but ranks below every preferred time. Within a tier, times closer to the
+// target win.
+type TimeMatch struct {
+	Preferred   []string      `json:"preferred,omitempty"` // HH:MM, best first
+	Tolerance   time.Duration `json:"tolerance,omitempty"`
+	WindowStart string        `json:"windowStart,omitempty"` // HH:MM, inclusive
+	WindowEnd   string        `json:"windowEnd,omitempty"`   // HH:MM, inclusive
+}
+
+// IsZero reports whether m sets no criteria at all.
+func (m TimeMatch) IsZero() bool {
+	return len(m.Preferred) == 0 && m.Tolerance == 0 && m.WindowStart == "" && m.WindowEnd == ""
+}
+
+func (m TimeMatch) equal(o TimeMatch) bool {
+	return slices.Equal(m.Preferred, o.Preferred) && m.Tolerance == o.Tolerance &&
+		m.WindowStart == o.WindowStart && m.WindowEnd == o.WindowEnd
+}
+
+// validate checks every time is HH:MM and the window is well formed,
+// reporting each problem as a *FieldError.
+func (m TimeMatch) validate() error {
+	var errs []error
+	for _, t := range m.Preferred {
+		if _, err := clock(t); err != nil {
+			errs = append(errs, inField("preferred", fmt.Errorf("preferred time %q: want HH:MM", t)))
+		}
+	}
+	if m.Tolerance < 0 {
+		errs = append(errs, inField("tolerance", fmt.Errorf("tolerance must not be negative")))
+	}
+	switch {
+	case m.WindowStart == "" && m.WindowEnd == "":
+	case m.WindowStart == "":
+		errs = append(errs, inField("windowStart", fmt.Errorf("window needs both a start and an end")))
+	case m.WindowEnd == "":
+		errs = append(errs, inField("windowEnd", fmt.Errorf("window needs both a start and an end")))
+	default:
+		from, errFrom := clock(m.WindowStart)
+		to, errTo := clock(m.WindowEnd)
+		switch {
+		case errFrom != nil:
+			errs = append(errs, inField("windowStart", fmt.Errorf("window start %q: want HH:MM", m.WindowStart)))
+		case errTo != nil:
+			errs = append(errs, inField("windowEnd", fmt.Errorf("window end %q: want HH:MM", m.WindowEnd)))
+		case to < from:
+			errs = append(errs, inField("windowEnd", fmt.Errorf("window %s-%s ends before it starts", m.WindowStart, m.WindowEnd)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// String describes the criteria for terminal output.
+func (m TimeMatch) String() string {
+	s := ""
+	if len(m.Preferred) > 0 {
+		s = fmt.Sprint(m.Preferred)
+		if m.Tolerance > 0 {
+			s += fmt.Sprintf(" ±%s", m.Tolerance)
+		}
+	}
+	if m.WindowStart != "" {
+		if s != "" {
+			s += ", "
+		}
+		s += "window " + m.WindowStart + "-" + m.WindowEnd
+	}
+	return s
+}
+
+// clock parses "HH:MM" into minutes after midnight.
+func clock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matchRank orders matches: lower tier first, then smaller distance,
+// then the more preferred party size.
+type matchRank struct {
+	tier  int // index into Preferred; len(Preferred) for window-only hits
+	dist  int // minutes away from the target time
+	party int // index into Watch.PartySizes
+}
+
+func (r matchRank) less(o matchRank) bool {
+	if r.tier != o.tier {
+		return r.tier < o.tier
+	}
+	if r.dist != o.dist {
+		return r.dist < o.dist
+	}
+	return r.party < o.party
+}
+
+// matcher evaluates slot times against a watch's criteria.
+type matcher struct {
+	preferred []int       // minutes after midnight, best first
+	tolerance int         // minutes
+	from, to  int         // window; from > to = no window
+	target    int         // what window-only hits are measured against
+	parties   map[int]int // party size → preference index
+}
+
+// newMatcher builds the matcher for w. Times were validated by Add, so
+// parse errors only happen for hand-built watches and simply never match.
+func newMatcher(w Watch) matcher {
+	m := w.Match
+	pref := m.Preferred
+	if len(pref) == 0 {
+		pref = []string{w.TimePref}
+	}
+	mt := matcher{tolerance: int(m.Tolerance / time.Minute), from: 1, to: 0}
+	for _, p := range pref {
+		if t, err := clock(p); err == nil {
+			mt.preferred = append(mt.preferred, t)
+		}
+	}
+	if m.WindowStart != "" {
+		mt.from, _ = clock(m.WindowStart)
+		mt.to, _ = clock(m.WindowEnd)
+	}
+	mt.parties = map[int]int{}
+	for i, n := range w.PartySizes() {
+		mt.parties[n] = i
+	}
+	if t, err := clock(w.TimePref); err == nil {
+		mt.target = t
+	} else if len(mt.preferred) > 0 {
+		mt.target = mt.preferred[0]
+	}
+	return mt
+}
+
+// rank reports whether hh:mm matches and, if so, how good it is.
+func (mt matcher) rank(hhmm string) (matchRank, bool) {
+	t, err := clock(hhmm)
+	if err != nil {
+		return matchRank{}, false
+	}
+	for i, p := range mt.preferred {
+		if d := abs(t - p); d <= mt.tolerance {
+			return matchRank{tier: i, dist: d}, true
+		}
+	}
+	if mt.from <= mt.to && t >= mt.from && t <= mt.to {
+		return matchRank{tier: len(mt.preferred), dist: abs(t - mt.target)}, true
+	}
+	return matchRank{}, false
+}
+
+// matches returns every matching slot in current, best first.
+func (mt matcher) matches(current []slotInfo) []slotInfo {
+	type ranked struct {
+		s slotInfo
+		r matchRank
+	}
+	var hits []ranked
+	for _, s := range current {
+		if r, ok := mt.rank(s.Time); ok {
+			r.party = mt.parties[s.Party]
+			hits = append(hits, ranked{s, r})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].r != hits[j].r {
+			return hits[i].r.less(hits[j].r)
+		}
+		return hits[i].s.Time < hits[j].s.Time
+	})
+	out := make([]slotInfo, len(hits))
+	for i, h := range hits {
+		out[i] = h.s
+	}
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -3,7 +3,6 @@ package monitor
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 )
 
@@ -12,6 +11,8 @@ type NotificationCallback func(exactMatch bool, reservationURL string, alternati
 
 // StartMonitor polls OpenTable every minute. It calls the callback function
 // when the slot set changes: a new slot appears or an old one disappears.
+// date is a single YYYY-MM-DD or any form ParseDateSpec accepts; ranges
+// are watched date by date and finish once any date matches.
 func (c *Client) StartMonitor(
 	ctx context.Context,
 	restaurantID string,
@@ -20,130 +21,92 @@ func (c *Client) StartMonitor(
 	partySize int,
 	callback NotificationCallback,
 ) error {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
-
-	fmt.Printf("🔎  Watching %s on %s (%s, party %d)…\n",
-		restaurantID, date, timePref, partySize)
-
-	// prev holds last-seen slots (key = SlotHash)
-	prev := map[string]slotInfo{}
-
-	// onePoll() – returns true when preferred slot found
-	onePoll := func() (bool, error) {
-		current, token, rid, err := c.fetchSlots(ctx, restaurantID, date, timePref, partySize)
-		if err != nil {
-			return false, err
-		}
-
-		// mapify current for quick lookup
-		now := make(map[string]slotInfo, len(current))
-		for _, s := range current {
-			now[s.SlotHash] = s
-		}
-
-		// exact preferred slot?
-		exactHash := hashOfExact(current, timePref)
-		if slot, ok := now[exactHash]; ok {
-			fmt.Printf("\n🎉  Exact slot FOUND — %s at %s\n", date, slot.Time)
-
-			// Call callback for exact match if provided
+	spec, err := ParseDateSpec(date)
+	if err != nil {
+		return err
+	}
+	w := Watch{
+		ID:           restaurantID,
+		RestaurantID: restaurantID,
+		TimePref:     timePref,
+		PartySize:    partySize,
+		OnEvent: func(ev WatchEvent) {
 			if callback != nil {
-				reservationURL := slot.buildURL(date, partySize, token, rid)
-				callback(true, reservationURL, nil)
-			} else {
+				callback(ev.Exact, ev.URL, ev.Alternatives)
+			} else if ev.Exact {
 				// Fallback to console output if no callback
-				fmt.Printf("%s\n", slot.buildURL(date, partySize, token, rid))
-			}
-			return true, nil
-		}
-
-		added := []slotInfo{}
-		for h, s := range now {
-			if _, seen := prev[h]; !seen {
-				added = append(added, s)
-			}
-		}
-
-		removed := []slotInfo{}
-		for h, s := range prev {
-			if _, still := now[h]; !still {
-				removed = append(removed, s)
-			}
-		}
-
-		if len(added) == 0 && len(removed) == 0 {
-			// nothing changed -> stay silent
-			return false, nil
-		}
-
-		// Handle changes
-		if len(prev) == 0 {
-			fmt.Printf("\n⏰  Preferred %s unavailable — %d alternative time(s):\n",
-				timePref, len(current))
-		}
-
-		if len(added) > 0 {
-			if len(prev) != 0 { // skip label for first big print
-				fmt.Printf("\n➕  %d new slot(s):\n", len(added))
+				fmt.Fprintf(Output, "%s\n", ev.URL)
 			}
+		},
+	}
+	if spec.IsSingle() {
+		w.Date = spec.Dates[0]
+		return c.StartWatch(ctx, w)
+	}
 
-			reservationURL := "" // the first URL (for “Book Now”)
-			alternativeTimes := make([]string, 0, len(added))
-
-			for _, s := range added {
-				attr := strings.Join(s.Attributes, ",")
-				url := s.buildURL(date, partySize, token, rid)
-
-				// terminal output
-				fmt.Printf("   • %s [%s] → %s\n", s.Time, attr, url)
-
-				// discord output
-				alternativeTimes = append(alternativeTimes,
-					fmt.Sprintf("• %s [%s] → [Book](%s)", s.Time, attr, url))
-
-				if reservationURL == "" {
-					reservationURL = url
-				}
-			}
-
-			// forward to caller (Discord) if requested
-			if callback != nil && len(alternativeTimes) > 0 {
-				callback(false, reservationURL, alternativeTimes)
-			}
-		}
-
-		if len(removed) > 0 {
-			fmt.Printf("\n➖  %d slot(s) disappeared:\n", len(removed))
-			for _, s := range removed {
-				attr := strings.Join(s.Attributes, ",")
-				fmt.Printf("   • %s [%s] (slotHash %s)\n",
-					s.Time, attr, s.SlotHash)
-			}
-		}
-
-		// update cache
-		prev = now
-		return false, nil
+	wl := c.NewWatchList()
+	if err := wl.AddRange(w, spec); err != nil {
+		return err
 	}
+	return wl.Run(ctx)
+}
 
-	// first poll (always prints the full list once)
-	if ok, err := onePoll(); err != nil || ok {
+// StartWatch polls a single watch every minute (or every w.Interval,
+// shaped by w.Schedule).
+// The best-ranked match under w.Match is reported through w.OnEvent;
+// with PolicyStop it then returns, with PolicyContinue it keeps polling
+// and reports again whenever the best match changes.
+func (c *Client) StartWatch(ctx context.Context, w Watch) error {
+	if err := w.Validate(); err != nil {
 		return err
 	}
+	return c.startWatch(ctx, w, newSlotTracker())
+}
+
+// startWatch runs a validated watch, carrying on from the slots tr has
+// already seen.
+func (c *Client) startWatch(ctx context.Context, w Watch, tr *slotTracker) error {
+	interval := 60 * time.Second
+	if w.Interval > 0 {
+		interval = w.Interval
+	}
 
-	// subsequent polls (every 60 s)
+	fmt.Fprintf(Output, "🔎  Watching %s…\n", w)
+
+	var h health
+
+	// the first poll runs straight away (and prints the full list once);
+	// later ones are spaced by the watch's schedule, or by the retry
+	// backoff after a failure
+	next := time.Now()
 	for {
+		timer := time.NewTimer(max(time.Until(next), c.breaker.wait(time.Now())))
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return ctx.Err()
-		case <-ticker.C:
-			if ok, err := onePoll(); err != nil {
+		case <-timer.C:
+		}
+		c.maybeRefreshSession(ctx)
+		ok, _, err := c.pollWatch(ctx, w, tr)
+		now := time.Now()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			wait, change, giveUp := h.fail(w.Retry, err, now)
+			if giveUp {
 				return err
-			} else if ok {
-				return nil
 			}
+			reportFailure(w, h.failures, wait, err, change)
+			next = now.Add(wait)
+			continue
+		}
+		reportHealth(w, h.ok())
+		if ok {
+			return nil
 		}
+		next = w.Schedule.next(now, interval, w.Date)
 	}
 }
 
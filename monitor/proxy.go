@@ -0,0 +1,426 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	tls_client "github.com/bogdanfinn/tls-client"
+)
+
+// How a proxy that keeps failing is taken out of rotation.
+const (
+	proxyStrikes     = 3 // blocked or failed requests in a row that evict a proxy
+	proxyCooldown    = 15 * time.Minute
+	maxProxyCooldown = 4 * time.Hour
+)
+
+// ProxyMode decides which proxy of a pool a request goes out through.
+type ProxyMode string
+
+const (
+	ProxyRoundRobin ProxyMode = "round-robin" // the next proxy for every request
+	ProxySticky     ProxyMode = "sticky"      // one proxy per watch until it's evicted
+)
+
+// ProxyStats are the counters of one proxy.
+type ProxyStats struct {
+	Proxy        string        `json:"proxy"` // URL, password redacted
+	Requests     int           `json:"requests"`
+	Blocked      int           `json:"blocked"` // 403, 429 or a bot challenge
+	Failed       int           `json:"failed"`  // no response at all
+	Latency      time.Duration `json:"latency"` // average time to a response
+	Evictions    int           `json:"evictions"`
+	EvictedUntil time.Time     `json:"evictedUntil,omitempty"`
+	LastUsed     time.Time     `json:"lastUsed,omitempty"`
+}
+
+func (s ProxyStats) String() string {
+	line := fmt.Sprintf("%s: %d requests, %d blocked, %d failed, avg %s",
+		s.Proxy, s.Requests, s.Blocked, s.Failed, s.Latency.Round(time.Millisecond))
+	if s.Evictions > 0 {
+		line += fmt.Sprintf(", evicted %d×", s.Evictions)
+	}
+	if time.Now().Before(s.EvictedUntil) {
+		line += " (out until " + s.EvictedUntil.Format("15:04") + ")"
+	}
+	return line
+}
+
+// proxy is one member of a pool.
+type proxy struct {
+	url     string
+	stats   ProxyStats
+	strikes int // blocked or failed requests in a row
+	trips   int // evictions without a good request in between
+}
+
+// ProxyPool spreads requests over a set of HTTP or SOCKS5 proxies,
+// taking the ones the site blocks out of rotation for a while. It is safe
+// for concurrent use.
+type ProxyPool struct {
+	mode ProxyMode
+
+	mu      sync.Mutex
+	proxies []*proxy
+	next    int
+	sticky  map[string]*proxy // watch ID → its proxy
+}
+
+// NewProxyPool builds a pool from proxy addresses as accepted by
+// ParseProxy. An empty mode means round-robin.
+func NewProxyPool(addrs []string, mode ProxyMode) (*ProxyPool, error) {
+	switch mode {
+	case "":
+		mode = ProxyRoundRobin
+	case ProxyRoundRobin, ProxySticky:
+	default:
+		return nil, fmt.Errorf("proxy mode %q: must be round-robin or sticky", mode)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no proxies")
+	}
+	p := &ProxyPool{mode: mode, sticky: map[string]*proxy{}}
+	seen := map[string]bool{}
+	for _, a := range addrs {
+		u, err := ParseProxy(a)
+		if err != nil {
+			return nil, err
+		}
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		p.proxies = append(p.proxies, &proxy{url: u, stats: ProxyStats{Proxy: redact(u)}})
+	}
+	return p, nil
+}
+
+// LoadProxies reads a pool from a file as read by ReadProxies.
+func LoadProxies(path string, mode ProxyMode) (*ProxyPool, error) {
+	addrs, err := ReadProxies(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := NewProxyPool(addrs, mode)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pool, nil
+}
+
+// ReadProxies reads a file with one proxy per line. Blank lines and
+// lines starting with # are skipped.
+func ReadProxies(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read proxies: %w", err)
+	}
+	defer f.Close()
+
+	var addrs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read proxies: %w", err)
+	}
+	return addrs, nil
+}
+
+// ParseProxy normalises a proxy address to a URL. It accepts http://,
+// https://, socks5:// and socks5h:// URLs, and the host:port and
+// host:port:user:password shorthands for HTTP proxies.
+func ParseProxy(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		switch parts := strings.Split(s, ":"); len(parts) {
+		case 2:
+			s = "http://" + s
+		case 4:
+			s = "http://" + url.UserPassword(parts[2], parts[3]).String() + "@" + parts[0] + ":" + parts[1]
+		default:
+			return "", fmt.Errorf("proxy %q: want scheme://[user:pass@]host:port, host:port or host:port:user:pass", s)
+		}
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("proxy %q: %w", s, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return "", fmt.Errorf("proxy %s: scheme must be http, https, socks5 or socks5h", redact(s))
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return "", fmt.Errorf("proxy %s: host and port are required", redact(s))
+	}
+	return u.String(), nil
+}
+
+// redact hides the password of a proxy URL.
+func redact(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
+
+// Mode returns how the pool assigns proxies.
+func (p *ProxyPool) Mode() ProxyMode { return p.mode }
+
+// Stats returns the counters of every proxy, in file order.
+func (p *ProxyPool) Stats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ProxyStats, len(p.proxies))
+	for i, px := range p.proxies {
+		out[i] = px.stats
+	}
+	return out
+}
+
+// pick chooses the proxy for a request made on behalf of watch key ("" =
+// none). cur is the proxy the client used last, which a sticky pool keeps
+// for requests that aren't any watch's. When every proxy is evicted the
+// one due back first is used rather than none.
+func (p *ProxyPool) pick(key string, cur *proxy, now time.Time) *proxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	up := func(px *proxy) bool { return px != nil && !now.Before(px.stats.EvictedUntil) }
+
+	if p.mode == ProxySticky {
+		if key == "" && up(cur) {
+			return cur
+		}
+		if px := p.sticky[key]; key != "" && up(px) {
+			return px
+		}
+	}
+	var px *proxy
+	for range p.proxies {
+		cand := p.proxies[p.next%len(p.proxies)]
+		p.next++
+		if up(cand) {
+			px = cand
+			break
+		}
+	}
+	if px == nil {
+		px = p.proxies[0]
+		for _, cand := range p.proxies[1:] {
+			if cand.stats.EvictedUntil.Before(px.stats.EvictedUntil) {
+				px = cand
+			}
+		}
+	}
+	if p.mode == ProxySticky && key != "" {
+		p.sticky[key] = px
+	}
+	return px
+}
+
+// record counts the outcome of one request through px: err for no
+// response, blocked for the site turning it away. Too many bad outcomes
+// in a row evict the proxy, for longer each time.
+func (p *ProxyPool) record(px *proxy, blocked bool, err error, latency time.Duration, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := &px.stats
+	s.Requests++
+	s.LastUsed = now
+	switch {
+	case err != nil:
+		s.Failed++
+	case blocked:
+		s.Blocked++
+	}
+	if err == nil {
+		s.Latency += (latency - s.Latency) / time.Duration(s.Requests-s.Failed)
+	}
+	if err == nil && !blocked {
+		px.strikes, px.trips = 0, 0
+		return
+	}
+	px.strikes++
+	if px.strikes < proxyStrikes {
+		return
+	}
+
+	cooldown := proxyCooldown
+	for i := 0; i < px.trips && cooldown < maxProxyCooldown; i++ {
+		cooldown *= 2
+	}
+	cooldown = min(cooldown, maxProxyCooldown)
+	px.trips++
+	s.Evictions++
+	s.EvictedUntil = now.Add(cooldown)
+	px.strikes = 0
+	for k, v := range p.sticky {
+		if v == px {
+			delete(p.sticky, k) // its watches move to another proxy
+		}
+	}
+	fmt.Fprintf(Output, "🚫  Proxy %s evicted for %s after %d bad requests in a row\n", s.Proxy, cooldown, proxyStrikes)
+}
+
+type proxyKey struct{}
+
+// withProxyKey marks requests made with ctx as watch key's, so a sticky
+// pool sends them through the same proxy.
+func withProxyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, proxyKey{}, key)
+}
+
+// ProxyStats returns the counters of the client's proxies, or nil when it
+// doesn't use any.
+func (c *Client) ProxyStats() []ProxyStats {
+	if c.proxies == nil {
+		return nil
+	}
+	return c.proxies.Stats()
+}
+
+// CheckProxies sends one request for the home page through every proxy
+// of the pool, evicted ones included, and returns the updated counters.
+func (c *Client) CheckProxies(ctx context.Context) ([]ProxyStats, error) {
+	if c.proxies == nil {
+		return nil, fmt.Errorf("no proxies configured")
+	}
+	c.proxies.mu.Lock()
+	list := append([]*proxy(nil), c.proxies.proxies...)
+	c.proxies.mu.Unlock()
+
+	for _, px := range list {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, BaseURL+"/", nil)
+		if err != nil {
+			return nil, fmt.Errorf("build req: %w", err)
+		}
+		req.Header = baseHeaders()
+		if _, err := c.fetchVia(px, req); err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return c.proxies.Stats(), nil
+}
+
+// fetch sends req, through the proxy the pool picks if there is one, and
+// reads the whole response.
+func (c *Client) fetch(req *http.Request) (apiResponse, error) {
+	if c.proxies == nil {
+		return read(c.conn(), req)
+	}
+	key, _ := req.Context().Value(proxyKey{}).(string)
+	c.mu.Lock()
+	cur := c.proxy
+	c.mu.Unlock()
+	return c.fetchVia(c.proxies.pick(key, cur, time.Now()), req)
+}
+
+// fetchVia sends req through px and counts the outcome against it.
+func (c *Client) fetchVia(px *proxy, req *http.Request) (apiResponse, error) {
+	tc, err := c.connVia(px)
+	if err != nil {
+		return apiResponse{}, fmt.Errorf("proxy %s: %w", px.stats.Proxy, err)
+	}
+	start := time.Now()
+	res, err := read(tc, req)
+	if req.Context().Err() != nil {
+		return res, err // not the proxy's fault
+	}
+	blocked := res.status == http.StatusForbidden || res.status == http.StatusTooManyRequests || challenge(res)
+	c.proxies.record(px, blocked, err, time.Since(start), time.Now())
+	return res, err
+}
+
+// connVia returns the TLS client of px, creating it on first use. Each
+// proxy gets a connection of its own rather than one client being
+// re-pointed between requests still in flight.
+func (c *Client) connVia(px *proxy) (tls_client.HttpClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.proxy = px
+	if tc, ok := c.conns[px]; ok {
+		return tc, nil
+	}
+	shared, ok := c.tls.GetCookieJar().(tls_client.CookieJar)
+	if !ok {
+		shared = cookieJar
+	}
+	tc, err := newTLSClient(proxyJar{own: tls_client.NewCookieJar(), shared: shared}, tls_client.WithProxyUrl(px.url))
+	if err != nil {
+		return nil, err
+	}
+	if c.conns == nil {
+		c.conns = map[*proxy]tls_client.HttpClient{}
+	}
+	c.conns[px] = tc
+	return tc, nil
+}
+
+// proxyJar is the cookie jar of one proxy's connection. Bot-management
+// cookies are tied to the IP they were handed to, so each proxy keeps
+// its own; every other cookie, the sign-in included, goes to the jar
+// the client shares between them.
+type proxyJar struct {
+	own, shared tls_client.CookieJar
+}
+
+func (j proxyJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	var own, shared []*http.Cookie
+	for _, ck := range cookies {
+		if isBotCookie(ck.Name) {
+			own = append(own, ck)
+		} else {
+			shared = append(shared, ck)
+		}
+	}
+	if len(own) > 0 {
+		j.own.SetCookies(u, own)
+	}
+	if len(shared) > 0 {
+		j.shared.SetCookies(u, shared)
+	}
+}
+
+func (j proxyJar) Cookies(u *url.URL) []*http.Cookie {
+	return append(j.shared.Cookies(u), j.own.Cookies(u)...)
+}
+
+func (j proxyJar) GetAllCookies() map[string][]*http.Cookie {
+	all := j.shared.GetAllCookies()
+	for host, cookies := range j.own.GetAllCookies() {
+		// the slices are the shared jar's own; appending could write into them
+		all[host] = append(slices.Clone(all[host]), cookies...)
+	}
+	return all
+}
+
+// read sends req on tc and reads the whole response.
+func read(tc tls_client.HttpClient, req *http.Request) (apiResponse, error) {
+	resp, err := tc.Do(req)
+	if err != nil {
+		return apiResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apiResponse{}, fmt.Errorf("read body: %w", err)
+	}
+	return apiResponse{status: resp.StatusCode, header: resp.Header, body: raw}, nil
+}
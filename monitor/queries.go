@@ -0,0 +1,426 @@
+package monitor
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// rediscoverEvery limits how often an unknown hash sends the client
+// looking through the site's scripts for the current one.
+const rediscoverEvery = time.Hour
+
+// Limits on one discovery: scripts downloaded, and how far a document
+// may run past its name.
+const (
+	maxBundles  = 60
+	maxQueryLen = 64 << 10
+)
+
+// hashPattern matches a quoted sha256 hex digest in a script.
+var hashPattern = regexp.MustCompile(`["'\x60]([0-9a-f]{64})["'\x60]`)
+
+// Operation is one GraphQL operation the client sends as a persisted
+// query: by hash alone, with the full document as a fallback.
+type Operation struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`            // "query" or "mutation"
+	Hash  string `json:"sha256Hash"`      // what the site knows the document by
+	Query string `json:"query,omitempty"` // the document itself, if known
+
+	pageGroup, pageType, timeout string // headers the site sends with it
+}
+
+// Names of the operations the client uses.
+const (
+	OpRestaurantsAvailability = "RestaurantsAvailability"
+	OpAutocomplete            = "Autocomplete"
+	OpSlotLock                = "BookDetailsStandardSlotLock"
+)
+
+var (
+	queriesMu sync.RWMutex
+	queries   = map[string]*Operation{
+		OpRestaurantsAvailability: {
+			Name: OpRestaurantsAvailability, Type: "query",
+			Hash:      "c056cbf4dbe6a95dbb5f814916415dcff0b2c93c180a456d0d4a3a3f38d0b2cc",
+			pageGroup: "rest-profile", pageType: "restprofilepage", timeout: "5500",
+		},
+		OpAutocomplete: {
+			Name: OpAutocomplete, Type: "query",
+			Hash:      "fe1d118abd4c227750693027c2414d43014c2493f64f49bcef5a65274ce9c3c3",
+			pageGroup: "search", pageType: "multi-search", timeout: "1500",
+		},
+		OpSlotLock: {
+			Name: OpSlotLock, Type: "mutation",
+			Hash:      "1100bf68905fd7cb1d4fd0f4504a4954aa28ec45fb22913fa977af8b06fd97fa",
+			pageGroup: "booking", pageType: "booking-details", timeout: "10000",
+		},
+	}
+)
+
+// Queries returns the current registry, sorted by name.
+func Queries() []Operation {
+	queriesMu.RLock()
+	defer queriesMu.RUnlock()
+	out := make([]Operation, 0, len(queries))
+	for _, op := range queries {
+		out = append(out, *op)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// SetQuery overrides the hash (and, when query is non-empty, the
+// document) of a registered operation. A client that has since learned
+// another hash from the site keeps using that one.
+func SetQuery(name, hash, query string) error {
+	if err := CheckQuery(name, hash); err != nil {
+		return err
+	}
+	queriesMu.Lock()
+	defer queriesMu.Unlock()
+	op := queries[name]
+	op.Hash = hash
+	if query != "" {
+		op.Query = query
+	}
+	return nil
+}
+
+// CheckQuery reports whether name is a registered operation and hash a
+// sha256 hex digest.
+func CheckQuery(name, hash string) error {
+	queriesMu.RLock()
+	_, ok := queries[name]
+	queriesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown query %q", name)
+	}
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 64 {
+		return fmt.Errorf("query %s: hash %q is not a sha256 hex digest", name, hash)
+	}
+	return nil
+}
+
+// LoadQueries applies the overrides in a JSON file as written by
+// SaveQueries. A missing file is not an error.
+func LoadQueries(path string) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read queries: %w", err)
+	}
+	var ops []Operation
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return fmt.Errorf("decode queries %s: %w", path, err)
+	}
+	for _, op := range ops {
+		if err := SetQuery(op.Name, op.Hash, op.Query); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// SaveQueries writes the current registry to path for LoadQueries.
+func SaveQueries(path string) error {
+	raw, err := json.MarshalIndent(Queries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, append(raw, '\n'), 0o755); err != nil {
+		return fmt.Errorf("write queries: %w", err)
+	}
+	return nil
+}
+
+func lookupQuery(name string) Operation {
+	queriesMu.RLock()
+	defer queriesMu.RUnlock()
+	return *queries[name]
+}
+
+// query returns operation name as this client sends it: what it learned
+// from the site itself, else the registry.
+func (c *Client) query(name string) Operation {
+	c.mu.Lock()
+	op, ok := c.learned[name]
+	c.mu.Unlock()
+	if ok {
+		return op
+	}
+	return lookupQuery(name)
+}
+
+// learnQuery makes this client send name by hash (and query, when
+// non-empty) from now on, leaving the registry and other clients alone.
+func (c *Client) learnQuery(name, hash, query string) {
+	op := c.query(name)
+	op.Hash = hash
+	if query != "" {
+		op.Query = query
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.learned == nil {
+		c.learned = map[string]Operation{}
+	}
+	c.learned[name] = op
+}
+
+// startDiscovery reports whether the site's scripts may be searched now,
+// and if so marks the search as started.
+func (c *Client) startDiscovery() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.discovered) < rediscoverEvery {
+		return false
+	}
+	c.discovered = time.Now()
+	return true
+}
+
+// graphQL runs the registered operation name with vars and decodes its
+// data into data. When the site no longer knows the hash, the full
+// document is sent instead (registering it, Apollo APQ style); without
+// a document the site's scripts are searched for the current hash once
+// an hour.
+func (c *Client) graphQL(ctx context.Context, name string, vars map[string]any, data any) error {
+	op := c.query(name)
+	err := c.runQuery(ctx, op, false, vars, data)
+	if !errors.Is(err, ErrPersistedQueryNotFound) {
+		return err
+	}
+
+	if op.Query != "" {
+		fmt.Fprintf(Output, "🧩  %s: hash unknown to the site, sending the full query\n", name)
+		if err = c.runQuery(ctx, op, true, vars, data); err == nil {
+			c.learnQuery(name, queryHash(op.Query), "") // the site has it under this hash now
+			return nil
+		}
+	}
+	if !c.startDiscovery() {
+		return fmt.Errorf("%w (refresh hashes with the queries command)", err)
+	}
+	fmt.Fprintf(Output, "🧩  %s: hash unknown to the site, looking for the current one…\n", name)
+	found, derr := c.DiscoverQueries(ctx)
+	if derr != nil {
+		return fmt.Errorf("%w; rediscovery failed: %v", err, derr)
+	}
+	for _, f := range found {
+		c.learnQuery(f.Name, f.Hash, f.Query)
+	}
+	fresh := c.query(name)
+	switch {
+	case fresh.Hash != op.Hash:
+		return c.runQuery(ctx, fresh, false, vars, data)
+	case fresh.Query != op.Query:
+		return c.runQuery(ctx, fresh, true, vars, data)
+	}
+	return fmt.Errorf("%w; no newer hash in the site's scripts", err)
+}
+
+// runQuery sends one GraphQL request for op, with the document attached
+// when withQuery is set.
+func (c *Client) runQuery(ctx context.Context, op Operation, withQuery bool, vars map[string]any, data any) error {
+	hash := op.Hash
+	payload := map[string]any{
+		"operationName": op.Name,
+		"variables":     vars,
+	}
+	if withQuery {
+		payload["query"] = op.Query
+		hash = queryHash(op.Query)
+	}
+	payload["extensions"] = map[string]any{
+		"persistedQuery": map[string]any{
+			"version":    1,
+			"sha256Hash": hash,
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/dapi/fe/gql?optype=%s&opname=%s", BaseURL, op.Type, op.Name)
+	res, err := c.send(ctx, http.MethodPost, u, body, op.pageGroup, op.pageType, op.timeout)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	return decodeGraphQL(op.Name, res, data)
+}
+
+// queryHash is the persisted-query hash of a GraphQL document.
+func queryHash(doc string) string {
+	sum := sha256.Sum256([]byte(doc))
+	return hex.EncodeToString(sum[:])
+}
+
+// orHash keeps a found hash, or derives one from the document.
+func orHash(hash, doc string) string {
+	if doc == "" {
+		return hash
+	}
+	return cmp.Or(hash, queryHash(doc))
+}
+
+// DiscoverQueries looks through the scripts the home page loads for the
+// registered operations, returning the hash (and, where the bundle carries
+// it, the document) found for each. Nothing is applied; pass the results
+// to SetQuery to use them.
+func (c *Client) DiscoverQueries(ctx context.Context) (map[string]Operation, error) {
+	page, err := c.fetchAsset(ctx, BaseURL+"/", "document")
+	if err != nil {
+		return nil, fmt.Errorf("home page: %w", err)
+	}
+	scripts, err := scriptURLs(page)
+	if err != nil {
+		return nil, err
+	}
+	if len(scripts) == 0 {
+		return nil, fmt.Errorf("home page: no scripts found")
+	}
+
+	ops := Queries()
+	found := map[string]Operation{}
+	for _, src := range scripts {
+		js, err := c.fetchAsset(ctx, src, "script")
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // one missing bundle shouldn't spoil the rest
+		}
+		for _, op := range ops {
+			if _, done := found[op.Name]; done {
+				continue
+			}
+			if f, ok := scanBundle(js, op); ok {
+				found[op.Name] = f
+			}
+		}
+		if len(found) == len(ops) {
+			break
+		}
+	}
+	return found, nil
+}
+
+// fetchAsset GETs a page or script from the site.
+func (c *Client) fetchAsset(ctx context.Context, u, dest string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build req: %w", err)
+	}
+	req.Header = baseHeaders()
+	if dest != "document" {
+		req.Header.Set("accept", "*/*")
+		req.Header.Set("sec-fetch-dest", dest)
+		req.Header.Set("sec-fetch-mode", "no-cors")
+		req.Header.Del("sec-fetch-user")
+		req.Header.Del("upgrade-insecure-requests")
+	}
+
+	res, err := c.fetch(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.status != http.StatusOK {
+		return nil, &StatusError{Code: res.status, Body: snippet(res.body)}
+	}
+	return res.body, nil
+}
+
+// scriptURLs lists the absolute URLs of the page's external scripts.
+func scriptURLs(page []byte) ([]string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("parse home page: %w", err)
+	}
+	base, err := url.Parse(BaseURL + "/")
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	seen := map[string]bool{}
+	doc.Find("script[src]").Each(func(_ int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		ref, err := url.Parse(strings.TrimSpace(src))
+		if err != nil || src == "" {
+			return
+		}
+		u := base.ResolveReference(ref).String()
+		if !seen[u] && len(out) < maxBundles {
+			seen[u] = true
+			out = append(out, u)
+		}
+	})
+	return out, nil
+}
+
+// scanBundle finds op in a script: the hash quoted closest after a
+// mention of its name, and the document if the bundle embeds it. A
+// document with no hash next to it is known by its own digest.
+func scanBundle(js []byte, op Operation) (Operation, bool) {
+	name := regexp.MustCompile(`\b` + regexp.QuoteMeta(op.Name) + `\b`)
+	var hash string
+	for _, loc := range name.FindAllIndex(js, -1) {
+		window := js[loc[1]:min(len(js), loc[1]+300)]
+		if m := hashPattern.FindSubmatch(window); m != nil {
+			hash = string(m[1])
+			break
+		}
+	}
+	doc := extractDocument(js, op)
+	if hash == "" && doc == "" {
+		return Operation{}, false
+	}
+	op.Hash, op.Query = orHash(hash, doc), doc
+	return op, true
+}
+
+// extractDocument pulls the GraphQL document defining op out of a
+// script, undoing the string escapes it was embedded with.
+func extractDocument(js []byte, op Operation) string {
+	start := regexp.MustCompile(op.Type + `\s+` + regexp.QuoteMeta(op.Name) + `\b`).FindIndex(js)
+	if start == nil {
+		return ""
+	}
+	depth := 0
+	for i := start[0]; i < min(len(js), start[0]+maxQueryLen); i++ {
+		switch js[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return unescapeJS(string(js[start[0] : i+1]))
+			}
+		}
+	}
+	return ""
+}
+
+// unescapeJS undoes the escapes of a JavaScript string literal.
+func unescapeJS(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	tls_client "github.com/bogdanfinn/tls-client"
+)
+
+// recoverCooldown is the least time between two recoveries. A request
+// that is still rejected right after one is a real block, and hammering
+// the site won't lift it.
+const recoverCooldown = 30 * time.Second
+
+// challengeMarkers are bits of the pages bot managers serve instead of an
+// API response.
+var challengeMarkers = []string{
+	"captcha",
+	"access denied",
+	"_incapsula_resource",
+	"/_sec/cp_challenge",
+	"please enable js",
+	"request unsuccessful",
+}
+
+// botCookies are the bot-management cookies that pin a client to a
+// challenge; they are dropped when the connection is reset.
+var botCookies = []string{"_abck", "bm_sz", "bm_sv", "bm_mi", "ak_bmsc", "_px", "incap_ses_", "visid_incap_", "reese84"}
+
+// apiResponse is a fully read API response.
+type apiResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// send performs one API request with the current CSRF token and reads the
+// whole response. When the site rejects the token or answers with a bot
+// challenge, the client recovers (see recoverSession) and the request is
+// retried once with the new token and connection.
+func (c *Client) send(ctx context.Context, method, u string, body []byte, pageGroup, pageType, timeout string) (apiResponse, error) {
+	res, err := c.roundTrip(ctx, method, u, body, pageGroup, pageType, timeout)
+	if err != nil {
+		return res, err
+	}
+	stale, challenged := rejected(res)
+	if !stale && !challenged {
+		return res, nil
+	}
+	if err := c.recoverSession(ctx, challenged); err != nil {
+		fmt.Fprintf(Output, "⚠️  %v\n", err)
+		return res, nil // let the caller report the original rejection
+	}
+	return c.roundTrip(ctx, method, u, body, pageGroup, pageType, timeout)
+}
+
+func (c *Client) roundTrip(ctx context.Context, method, u string, body []byte, pageGroup, pageType, timeout string) (apiResponse, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return apiResponse{}, fmt.Errorf("build req: %w", err)
+	}
+	req.Header = apiHeaders(c.token(), pageGroup, pageType, timeout)
+	return c.fetch(req)
+}
+
+// rejected tells a stale CSRF token (a bare 403) from a bot challenge (a
+// challenge page, or HTML where JSON was asked for).
+func rejected(res apiResponse) (stale, challenged bool) {
+	if challenge(res) {
+		return false, true
+	}
+	switch {
+	case res.status == http.StatusForbidden:
+		return true, false
+	case res.status == http.StatusOK && isHTML(res):
+		return false, true
+	}
+	return false, false
+}
+
+// challenge reports whether res is a bot-challenge page.
+func challenge(res apiResponse) bool {
+	lower := strings.ToLower(string(res.body[:min(len(res.body), 4096)]))
+	return isHTML(res) && slices.ContainsFunc(challengeMarkers, func(m string) bool { return strings.Contains(lower, m) })
+}
+
+// isHTML reports whether res is a web page rather than JSON.
+func isHTML(res apiResponse) bool {
+	return strings.Contains(res.header.Get("content-type"), "text/html") ||
+		bytes.HasPrefix(bytes.TrimSpace(res.body), []byte("<"))
+}
+
+// recoverSession gets the client past a rejected request. A stale token
+// is simply fetched again. A challenge, or a home page that won't hand
+// out a token, calls for a clean start: a new TLS connection and a jar
+// without the bot-management cookies, keeping any sign-in cookies.
+func (c *Client) recoverSession(ctx context.Context, challenged bool) error {
+	c.mu.Lock()
+	if time.Since(c.recovered) < recoverCooldown {
+		c.mu.Unlock()
+		return fmt.Errorf("still rejected right after recovering the session; giving up on this request")
+	}
+	c.recovered = time.Now()
+	c.mu.Unlock()
+
+	if challenged {
+		fmt.Fprintln(Output, "🛡️  Challenged by the site, starting a clean connection…")
+		if err := c.resetConnection(); err != nil {
+			return fmt.Errorf("recover session: %w", err)
+		}
+	} else {
+		fmt.Fprintln(Output, "🔄  CSRF token rejected, fetching a new one…")
+	}
+	err := c.refreshCSRF(ctx)
+	if err != nil && !challenged {
+		if err = c.resetConnection(); err == nil {
+			err = c.refreshCSRF(ctx)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("recover session: %w", err)
+	}
+	if c.session != nil {
+		c.mu.Lock()
+		c.session.checked = time.Time{} // confirm the sign-in on the next poll
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// resetConnection swaps in a new TLS client with a fresh cookie jar,
+// carrying over every cookie but the bot-management ones, and drops the
+// proxies' connections along with their bot-management cookies.
+func (c *Client) resetConnection() error {
+	jar := tls_client.NewCookieJar()
+	for host, cookies := range c.jar().GetAllCookies() {
+		keep := slices.DeleteFunc(slices.Clone(cookies), func(ck *http.Cookie) bool { return isBotCookie(ck.Name) })
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, keep)
+	}
+	tls, err := newTLSClient(jar)
+	if err != nil {
+		return fmt.Errorf("tls-client: %w", err)
+	}
+	c.mu.Lock()
+	old := append([]tls_client.HttpClient{c.tls}, slices.Collect(maps.Values(c.conns))...)
+	c.tls, c.conns = tls, nil // proxies get new connections on their next request
+	c.mu.Unlock()
+	for _, tc := range old {
+		tc.CloseIdleConnections()
+	}
+	return nil
+}
+
+// isBotCookie reports whether name is one of botCookies.
+func isBotCookie(name string) bool {
+	return slices.ContainsFunc(botCookies, func(p string) bool { return strings.HasPrefix(name, p) })
+}
@@ -1,6 +1,9 @@
 package monitor
 
 import (
+	"io"
+	"os"
+
 	http "github.com/bogdanfinn/fhttp"
 	tls_client "github.com/bogdanfinn/tls-client"
 )
@@ -12,6 +15,14 @@ var (
 	defaultSecChUA = "\"Google Chrome\";v=\"133\", \"Chromium\";v=\"133\", \"Not/A)Brand\";v=\"24\""
 )
 
+// BaseURL is the OpenTable site every request goes to. Point it at a
+// local fake server to exercise the client without touching the real one.
+var BaseURL = "https://www.opentable.ca"
+
+// Output receives the human-readable progress the monitor prints while
+// polling. Headless callers point it at os.Stderr to keep stdout clean.
+var Output io.Writer = os.Stdout
+
 // baseHeaders is copied onto each outbound request so we never drift.
 func baseHeaders() http.Header {
 	return http.Header{
@@ -45,3 +56,26 @@ func baseHeaders() http.Header {
 		},
 	}
 }
+
+// apiHeaders are the headers the site's own XHR calls send to its JSON
+// and GraphQL endpoints.
+func apiHeaders(csrf, pageGroup, pageType, timeout string) http.Header {
+	h := baseHeaders()
+	h.Set("accept", "*/*")
+	h.Set("content-type", "application/json")
+	h.Set("origin", BaseURL)
+	h.Set("ot-page-group", pageGroup)
+	h.Set("ot-page-type", pageType)
+	h.Set("priority", "u=1, i")
+	h.Set("sec-fetch-dest", "empty")
+	h.Set("sec-fetch-mode", "cors")
+	h.Set("sec-fetch-site", "same-origin")
+	h.Set("x-csrf-token", csrf)
+	h.Set("x-query-timeout", timeout)
+	h[http.HeaderOrderKey] = append(
+		h[http.HeaderOrderKey],
+		"content-type", "origin", "ot-page-group", "ot-page-type",
+		"x-csrf-token", "x-query-timeout",
+	)
+	return h
+}
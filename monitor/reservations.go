@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reservation is a booking on the signed-in account.
+type Reservation struct {
+	ID                 int64  `json:"reservationId"`
+	ConfirmationNumber string `json:"confirmationNumber"`
+	RestaurantID       int    `json:"restaurantId"`
+	RestaurantName     string `json:"restaurantName"`
+	Date               string `json:"date"` // YYYY-MM-DD
+	Time               string `json:"time"` // HH:MM
+	PartySize          int    `json:"partySize"`
+	Status             string `json:"status"`
+	SecurityToken      string `json:"-"` // proves ownership to cancel/modify
+}
+
+func (r Reservation) String() string {
+	return fmt.Sprintf("%s on %s at %s for %d (#%s)",
+		cmp.Or(r.RestaurantName, strconv.Itoa(r.RestaurantID)), r.Date, r.Time, r.PartySize, r.ConfirmationNumber)
+}
+
+// Matches reports whether ref names r, by reservation ID or confirmation
+// number.
+func (r Reservation) Matches(ref string) bool {
+	ref = strings.TrimPrefix(strings.TrimSpace(ref), "#")
+	return ref != "" && (ref == strconv.FormatInt(r.ID, 10) || strings.EqualFold(ref, r.ConfirmationNumber))
+}
+
+// ReservationChange is what ModifyReservation moves a booking to. Zero
+// fields keep the current value.
+type ReservationChange struct {
+	Date      string
+	Time      string
+	PartySize int
+}
+
+// Reservations lists the account's upcoming reservations, soonest first.
+func (c *Client) Reservations(ctx context.Context) ([]Reservation, error) {
+	if c.User() == nil {
+		return nil, ErrNotSignedIn
+	}
+	var out struct {
+		Reservations []struct {
+			ReservationID      int64  `json:"reservationId"`
+			ConfirmationNumber string `json:"confirmationNumber"`
+			RestaurantID       int    `json:"restaurantId"`
+			RestaurantName     string `json:"restaurantName"`
+			DateTime           string `json:"dateTime"` // 2025-07-04T19:00[:00]
+			PartySize          int    `json:"partySize"`
+			State              string `json:"reservationState"`
+			SecurityToken      string `json:"securityToken"`
+		} `json:"reservations"`
+	}
+	u := BaseURL + "/dapi/user/reservations?scope=upcoming"
+	if err := c.getJSON(ctx, u, "user", "user_reservations", &out); err != nil {
+		return nil, fmt.Errorf("list reservations: %w", err)
+	}
+
+	list := make([]Reservation, 0, len(out.Reservations))
+	for _, r := range out.Reservations {
+		date, hhmm, _ := strings.Cut(r.DateTime, "T")
+		if len(hhmm) > 5 {
+			hhmm = hhmm[:5]
+		}
+		list = append(list, Reservation{
+			ID:                 r.ReservationID,
+			ConfirmationNumber: r.ConfirmationNumber,
+			RestaurantID:       r.RestaurantID,
+			RestaurantName:     r.RestaurantName,
+			Date:               date,
+			Time:               hhmm,
+			PartySize:          r.PartySize,
+			Status:             strings.ToLower(r.State),
+			SecurityToken:      r.SecurityToken,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Date+list[i].Time < list[j].Date+list[j].Time
+	})
+	return list, nil
+}
+
+// FindReservation returns the upcoming reservation ref names (its ID or
+// confirmation number).
+func (c *Client) FindReservation(ctx context.Context, ref string) (Reservation, error) {
+	list, err := c.Reservations(ctx)
+	if err != nil {
+		return Reservation{}, err
+	}
+	for _, r := range list {
+		if r.Matches(ref) {
+			return r, nil
+		}
+	}
+	return Reservation{}, fmt.Errorf("no upcoming reservation %q", ref)
+}
+
+// CancelReservation cancels r.
+func (c *Client) CancelReservation(ctx context.Context, r Reservation) error {
+	u := c.User()
+	if u == nil {
+		return ErrNotSignedIn
+	}
+	payload := map[string]any{
+		"reservationId":      r.ID,
+		"confirmationNumber": r.ConfirmationNumber,
+		"restaurantId":       r.RestaurantID,
+		"securityToken":      r.SecurityToken,
+		"gpid":               u.ID,
+	}
+	var out struct {
+		Success      bool   `json:"success"`
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := c.postJSON(ctx, BaseURL+"/dapi/booking/cancel-reservation", "booking", "reservation_cancel", payload, &out); err != nil {
+		return fmt.Errorf("cancel reservation: %w", err)
+	}
+	if !out.Success {
+		return fmt.Errorf("cancel reservation: %s", cmp.Or(out.ErrorMessage, out.ErrorCode, "rejected without a reason"))
+	}
+	fmt.Fprintf(Output, "🗑️  Cancelled %s\n", r)
+	return nil
+}
+
+// ModifyReservation moves r to a new time, date and/or party size. The
+// exact slot must be open: it is looked up, locked and booked over r the
+// same way the site's "modify" flow does, so r is only released once the
+// new slot is secured.
+func (c *Client) ModifyReservation(ctx context.Context, r Reservation, ch ReservationChange) (*Booking, error) {
+	if c.User() == nil {
+		return nil, ErrNotSignedIn
+	}
+	date, hhmm, party := cmp.Or(ch.Date, r.Date), cmp.Or(ch.Time, r.Time), cmp.Or(ch.PartySize, r.PartySize)
+	if date == r.Date && hhmm == r.Time && party == r.PartySize {
+		return nil, fmt.Errorf("modify reservation: nothing to change")
+	}
+
+	avail, err := c.FetchAvailability(ctx, AvailabilityQuery{
+		RestaurantIDs: []int{r.RestaurantID},
+		Date:          date,
+		Time:          hhmm,
+		PartySize:     party,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("modify reservation: %w", err)
+	}
+	var slot *Slot
+	for _, s := range avail[r.RestaurantID][date] {
+		if s.Time == hhmm && !s.Experience {
+			slot = &s
+			break
+		}
+	}
+	if slot == nil {
+		return nil, fmt.Errorf("modify reservation: no %s slot on %s for %d", hhmm, date, party)
+	}
+
+	diner := Diner{}.orMember(c.User())
+	if err := diner.Validate(); err != nil {
+		return nil, fmt.Errorf("modify reservation: %w", err)
+	}
+	lockID, err := c.LockSlot(ctx, *slot)
+	if err != nil {
+		return nil, fmt.Errorf("modify reservation: %w", err)
+	}
+	res, err := c.makeReservation(ctx, *slot, lockID, diner, &r)
+	if err != nil {
+		return nil, fmt.Errorf("modify reservation: %w", err)
+	}
+
+	bk := &Booking{
+		RestaurantID:       r.RestaurantID,
+		Date:               date,
+		Time:               hhmm,
+		PartySize:          party,
+		ConfirmationNumber: cmp.Or(res.ConfirmationNumber, r.ConfirmationNumber),
+		ReservationID:      cmp.Or(res.ReservationID, r.ID),
+		SecurityToken:      cmp.Or(res.SecurityToken, r.SecurityToken),
+	}
+	fmt.Fprintf(Output, "✏️  Moved #%s from %s %s (%d) to %s %s (%d)\n",
+		r.ConfirmationNumber, r.Date, r.Time, r.PartySize, date, hhmm, party)
+	return bk, nil
+}
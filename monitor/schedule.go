@@ -0,0 +1,241 @@
+package monitor
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Defaults for the slow-down rules of a Schedule.
+const (
+	defaultQuietEvery = 10 * time.Minute
+	defaultFarEvery   = 5 * time.Minute
+	minPollGap        = time.Second
+)
+
+// Schedule shapes when a watch polls on top of its base interval. The zero
+// value polls at exactly the base interval.
+//
+// Fast windows win over everything else: a restaurant that drops its
+// calendar at 10:00 should be polled hard at 10:00 even at the far end of
+// a range. Otherwise the slower of the quiet-hours and far-date intervals
+// applies. Jitter then moves every poll by a random amount so requests
+// don't land on a fixed beat.
+type Schedule struct {
+	Jitter     time.Duration `json:"jitter,omitempty"`     // ± random offset on every wait
+	Fast       []FastWindow  `json:"fast,omitempty"`       // daily windows polled faster
+	Quiet      string        `json:"quiet,omitempty"`      // "HH:MM-HH:MM", may wrap midnight
+	QuietEvery time.Duration `json:"quietEvery,omitempty"` // interval inside Quiet; default 10m
+	FarDays    int           `json:"farDays,omitempty"`    // dates more than this many days out…
+	FarEvery   time.Duration `json:"farEvery,omitempty"`   // …poll this often; default 5m
+	TZ         string        `json:"tz,omitempty"`         // zone the windows are in; "" = local
+}
+
+// FastWindow is a daily span, e.g. a restaurant's release time, during
+// which a watch polls every Every.
+type FastWindow struct {
+	At    string        `json:"at"`    // HH:MM start
+	For   time.Duration `json:"for"`   // length
+	Every time.Duration `json:"every"` // poll interval inside the window
+}
+
+// ParseFastWindow reads the compact "10:00+15m@5s" form: from 10:00, for
+// 15 minutes, every 5 seconds.
+func ParseFastWindow(s string) (FastWindow, error) {
+	at, rest, ok := strings.Cut(strings.TrimSpace(s), "+")
+	length, every, ok2 := strings.Cut(rest, "@")
+	if !ok || !ok2 {
+		return FastWindow{}, fmt.Errorf("fast window %q: want HH:MM+LENGTH@EVERY, e.g. 10:00+15m@5s", s)
+	}
+	fw := FastWindow{At: at}
+	var err error
+	if fw.For, err = time.ParseDuration(length); err != nil {
+		return fw, fmt.Errorf("fast window %q: %w", s, err)
+	}
+	if fw.Every, err = time.ParseDuration(every); err != nil {
+		return fw, fmt.Errorf("fast window %q: %w", s, err)
+	}
+	return fw, fw.validate()
+}
+
+func (fw FastWindow) validate() error {
+	if _, err := clock(fw.At); err != nil {
+		return fmt.Errorf("fast window start %q: want HH:MM", fw.At)
+	}
+	if fw.For <= 0 || fw.For > 24*time.Hour {
+		return fmt.Errorf("fast window at %s: length must be between 0 and 24h", fw.At)
+	}
+	if fw.Every < minPollGap {
+		return fmt.Errorf("fast window at %s: interval must be at least %s", fw.At, minPollGap)
+	}
+	return nil
+}
+
+func (fw FastWindow) String() string {
+	return fmt.Sprintf("%s+%s@%s", fw.At, fw.For, fw.Every)
+}
+
+// IsZero reports whether s changes nothing about the base interval.
+func (s Schedule) IsZero() bool {
+	return s.Jitter == 0 && len(s.Fast) == 0 && s.Quiet == "" && s.FarDays == 0
+}
+
+// validate reports each problem with s as a *FieldError.
+func (s Schedule) validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, inField(field, err))
+		}
+	}
+	if s.Jitter < 0 {
+		check("jitter", fmt.Errorf("jitter must not be negative"))
+	}
+	for _, fw := range s.Fast {
+		check("fast", fw.validate())
+	}
+	if s.Quiet != "" {
+		if _, _, err := span(s.Quiet); err != nil {
+			check("quiet", fmt.Errorf("quiet hours %q: %w", s.Quiet, err))
+		}
+	}
+	if s.QuietEvery < 0 {
+		check("quietEvery", fmt.Errorf("quiet interval must not be negative"))
+	}
+	if s.FarDays < 0 {
+		check("farDays", fmt.Errorf("far days must not be negative"))
+	}
+	if s.FarEvery < 0 {
+		check("farEvery", fmt.Errorf("far interval must not be negative"))
+	}
+	if _, err := s.location(); err != nil {
+		check("tz", err)
+	}
+	return errors.Join(errs...)
+}
+
+// String describes the schedule for terminal output.
+func (s Schedule) String() string {
+	var parts []string
+	for _, fw := range s.Fast {
+		parts = append(parts, "fast "+fw.String())
+	}
+	if s.Quiet != "" {
+		parts = append(parts, "quiet "+s.Quiet)
+	}
+	if s.FarDays > 0 {
+		parts = append(parts, fmt.Sprintf("slow beyond %dd", s.FarDays))
+	}
+	if s.Jitter > 0 {
+		parts = append(parts, fmt.Sprintf("±%s", s.Jitter))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (s Schedule) location() (*time.Location, error) {
+	if s.TZ == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(s.TZ)
+	if err != nil {
+		return nil, fmt.Errorf("time zone %q: %w", s.TZ, err)
+	}
+	return loc, nil
+}
+
+// next returns when a watch for date that last polled at last should poll
+// again, given its base interval.
+func (s Schedule) next(last time.Time, base time.Duration, date string) time.Time {
+	if s.IsZero() {
+		return last.Add(base)
+	}
+	loc, err := s.location()
+	if err != nil {
+		loc = time.Local
+	}
+	now := last.In(loc)
+
+	interval := base
+	if s.FarDays > 0 {
+		if d, err := time.ParseInLocation("2006-01-02", date, loc); err == nil &&
+			d.Sub(now) > time.Duration(s.FarDays)*24*time.Hour {
+			interval = max(interval, cmp.Or(s.FarEvery, defaultFarEvery))
+		}
+	}
+	if s.Quiet != "" {
+		if from, to, err := span(s.Quiet); err == nil && inSpan(minuteOf(now), from, to) {
+			interval = max(interval, cmp.Or(s.QuietEvery, defaultQuietEvery))
+		}
+	}
+	for _, fw := range s.Fast {
+		if start, ok := fw.active(now); ok && now.Before(start.Add(fw.For)) {
+			interval = min(interval, fw.Every)
+		}
+	}
+
+	if s.Jitter > 0 {
+		j := min(s.Jitter, interval/2) // never let jitter swallow the interval
+		interval += time.Duration(rand.Int64N(int64(2*j)+1)) - j
+	}
+	next := now.Add(max(interval, minPollGap))
+
+	// never sleep through the start of a fast window
+	for _, fw := range s.Fast {
+		if start := fw.upcoming(now); start.After(now) && start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
+
+// active returns the start of the occurrence of fw that began most
+// recently at or before t.
+func (fw FastWindow) active(t time.Time) (time.Time, bool) {
+	m, err := clock(fw.At)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start := time.Date(t.Year(), t.Month(), t.Day(), m/60, m%60, 0, 0, t.Location())
+	if start.After(t) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start, true
+}
+
+// upcoming returns the next start of fw after t.
+func (fw FastWindow) upcoming(t time.Time) time.Time {
+	start, ok := fw.active(t)
+	if !ok {
+		return time.Time{}
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// span parses "HH:MM-HH:MM" into minutes after midnight.
+func span(s string) (from, to int, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("want HH:MM-HH:MM")
+	}
+	if from, err = clock(strings.TrimSpace(a)); err != nil {
+		return 0, 0, fmt.Errorf("want HH:MM-HH:MM")
+	}
+	if to, err = clock(strings.TrimSpace(b)); err != nil {
+		return 0, 0, fmt.Errorf("want HH:MM-HH:MM")
+	}
+	return from, to, nil
+}
+
+// inSpan reports whether minute m falls in [from, to), wrapping midnight
+// when to < from.
+func inSpan(m, from, to int) bool {
+	if from <= to {
+		return m >= from && m < to
+	}
+	return m >= from || m < to
+}
+
+func minuteOf(t time.Time) int { return t.Hour()*60 + t.Minute() }
@@ -0,0 +1,447 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	tls_client "github.com/bogdanfinn/tls-client"
+)
+
+// How often a signed-in client re-validates its session while
+// monitoring: routinely, and sooner once a sign-in cookie is about to
+// expire. Failed checks wait just as long before the next try.
+const (
+	sessionCheckEvery = 6 * time.Hour
+	sessionRetryEvery = 10 * time.Minute
+)
+
+// authCookies are the cookies that carry an OpenTable sign-in; only
+// their expiry says anything about the session.
+var authCookies = []string{"authCke", "OT-SessionId", "OT-Session-Update-Date", "ha_userSession"}
+
+// ErrNotSignedIn is returned for actions that need an OpenTable account
+// when the client has no valid session.
+var ErrNotSignedIn = errors.New("not signed in to OpenTable")
+
+// User is the OpenTable member a session belongs to.
+type User struct {
+	ID        int64  `json:"gpid"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	Phone     string `json:"phoneNumber,omitempty"`
+	Country   string `json:"phoneCountryId,omitempty"`
+	Points    int    `json:"points,omitempty"`
+}
+
+func (u *User) String() string {
+	return fmt.Sprintf("%s %s <%s>", u.FirstName, u.LastName, u.Email)
+}
+
+// Credentials sign in to an OpenTable account.
+type Credentials struct {
+	Email    string
+	Password string
+}
+
+// session is where a signed-in client keeps its cookies and how it signs
+// in again once they stop working.
+type session struct {
+	path    string
+	creds   Credentials
+	checked time.Time // last check of the sign-in; guarded by the client's mu
+}
+
+// sessionFile is the on-disk form of a session.
+type sessionFile struct {
+	SavedAt time.Time     `json:"savedAt"`
+	User    *User         `json:"user,omitempty"`
+	Cookies []savedCookie `json:"cookies"`
+}
+
+type savedCookie struct {
+	Host     string    `json:"host"` // cookie-jar key, e.g. opentable.ca
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// User returns the signed-in member, or nil for an anonymous client.
+func (c *Client) User() *User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.user
+}
+
+// setUser records who the session belongs to (nil = nobody).
+func (c *Client) setUser(u *User) {
+	c.mu.Lock()
+	c.user = u
+	c.mu.Unlock()
+}
+
+// UseSession keeps the client's signed-in cookies in the file at path,
+// loading whatever is saved there now. creds, if set, sign in again when
+// the cookies stop working. Call RefreshSession (or Login) next to check
+// the session; it is then re-checked periodically while watches run.
+func (c *Client) UseSession(path string, creds Credentials) error {
+	c.session = &session{path: path, creds: creds}
+	return c.loadSession()
+}
+
+// Login signs in with an email and password. OpenTable often answers a
+// new device with a one-time code instead; import cookies from a signed-in
+// browser (ImportCookies) in that case.
+func (c *Client) Login(ctx context.Context, creds Credentials) error {
+	if creds.Email == "" || creds.Password == "" {
+		return fmt.Errorf("login: email and password are required")
+	}
+	payload := map[string]any{
+		"email":      creds.Email,
+		"password":   creds.Password,
+		"rememberMe": true,
+	}
+	var out struct {
+		Success              bool   `json:"success"`
+		VerificationRequired bool   `json:"verificationRequired"`
+		ErrorCode            string `json:"errorCode"`
+		ErrorMessage         string `json:"errorMessage"`
+	}
+	if err := c.postJSON(ctx, BaseURL+"/dapi/authentication/login", "authentication", "sign_in", payload, &out); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	switch {
+	case out.VerificationRequired:
+		return fmt.Errorf("login: OpenTable wants a one-time code for this device; sign in with a browser and import its cookies instead")
+	case !out.Success:
+		msg := out.ErrorMessage
+		if msg == "" {
+			msg = out.ErrorCode
+		}
+		if msg == "" {
+			msg = "rejected"
+		}
+		return fmt.Errorf("login: %s", msg)
+	}
+
+	// the sign-in response rotates the CSRF token along with the cookies
+	if err := c.refreshCSRF(ctx); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	u, err := c.fetchUser(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	c.setUser(u)
+	return c.saveSession()
+}
+
+// ImportCookies loads cookies exported from a signed-in browser into the
+// client's jar. Both the JSON array written by cookie-export extensions
+// and the Netscape cookies.txt format are understood. Call RefreshSession
+// afterwards to confirm they sign the client in.
+func (c *Client) ImportCookies(path string) (int, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("import cookies: %w", err)
+	}
+	var cookies []savedCookie
+	if t := bytes.TrimSpace(raw); len(t) > 0 && t[0] == '[' {
+		cookies, err = parseJSONCookies(t)
+	} else {
+		cookies, err = parseNetscapeCookies(raw)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("import cookies %s: %w", path, err)
+	}
+	if len(cookies) == 0 {
+		return 0, fmt.Errorf("import cookies %s: no OpenTable cookies found", path)
+	}
+	c.setCookies(cookies)
+	return len(cookies), nil
+}
+
+// RefreshSession reloads the home page (renewing the CSRF token and any
+// rotating cookies), checks who the cookies belong to and saves them. An
+// expired session is renewed with the stored credentials if there are
+// any; otherwise the error wraps ErrNotSignedIn.
+func (c *Client) RefreshSession(ctx context.Context) error {
+	if err := c.refreshCSRF(ctx); err != nil {
+		c.setUser(nil)
+		return fmt.Errorf("refresh session: %w", err)
+	}
+	u, err := c.fetchUser(ctx)
+	if errors.Is(err, ErrNotSignedIn) && c.session != nil && c.session.creds.Email != "" {
+		fmt.Fprintln(Output, "🔑  Session expired, signing in again…")
+		err = c.Login(ctx, c.session.creds)
+		u = c.User()
+	}
+	if err != nil {
+		c.setUser(nil)
+		return fmt.Errorf("refresh session: %w", err)
+	}
+	c.setUser(u)
+	if c.session != nil {
+		c.mu.Lock()
+		c.session.checked = time.Now()
+		c.mu.Unlock()
+	}
+	return c.saveSession()
+}
+
+// maybeRefreshSession re-checks a signed-in session when it is due or a
+// cookie is about to expire. Failures are reported, not fatal: anonymous
+// availability still works, only member-only features stop.
+func (c *Client) maybeRefreshSession(ctx context.Context) {
+	s := c.session
+	if s == nil {
+		return
+	}
+	c.mu.Lock()
+	since := time.Since(s.checked)
+	c.mu.Unlock()
+	if since < sessionRetryEvery || since < sessionCheckEvery && !c.cookiesExpiring(time.Hour) {
+		return
+	}
+	c.mu.Lock()
+	s.checked = time.Now() // don't hammer the sign-in on every poll, failed or not
+	c.mu.Unlock()
+	if err := c.RefreshSession(ctx); err != nil {
+		fmt.Fprintf(Output, "⚠️  %v\n", err)
+	}
+}
+
+// refreshCSRF fetches a new CSRF token with the current cookies.
+func (c *Client) refreshCSRF(ctx context.Context) error {
+	csrf, err := c.fetchCSRFToken(ctx)
+	if err != nil {
+		return fmt.Errorf("csrf: %w", err)
+	}
+	c.mu.Lock()
+	c.csrf = csrf
+	c.mu.Unlock()
+	return nil
+}
+
+// fetchUser asks who the cookies belong to.
+func (c *Client) fetchUser(ctx context.Context) (*User, error) {
+	// no recovery here: a 401/403 just means the cookies aren't signed in
+	res, err := c.roundTrip(ctx, http.MethodGet, BaseURL+"/dapi/user/profile", nil, "user", "user_profile", "5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, challenged := rejected(res); challenged {
+		return nil, fmt.Errorf("user profile: %w", checkResponse(res))
+	}
+	switch {
+	case res.status == http.StatusUnauthorized || res.status == http.StatusForbidden:
+		return nil, ErrNotSignedIn
+	case res.status < 200 || res.status >= 300:
+		return nil, fmt.Errorf("user profile: %w", checkResponse(res))
+	}
+	var u User
+	if err := json.Unmarshal(res.body, &u); err != nil {
+		return nil, fmt.Errorf("decode user profile: %w", err)
+	}
+	if u.ID == 0 {
+		return nil, ErrNotSignedIn
+	}
+	return &u, nil
+}
+
+// jar returns the client's cookie jar.
+func (c *Client) jar() tls_client.CookieJar {
+	if j, ok := c.conn().GetCookieJar().(tls_client.CookieJar); ok {
+		return j
+	}
+	return cookieJar
+}
+
+// setCookies adds saved cookies to the jar, grouped by host.
+func (c *Client) setCookies(list []savedCookie) {
+	byHost := map[string][]*http.Cookie{}
+	for _, sc := range list {
+		byHost[sc.Host] = append(byHost[sc.Host], &http.Cookie{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Path:     sc.Path,
+			Expires:  sc.Expires,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HTTPOnly,
+		})
+	}
+	for host, cookies := range byHost {
+		c.jar().SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, cookies)
+	}
+}
+
+// cookiesExpiring reports whether any sign-in cookie with an expiry runs
+// out within d.
+func (c *Client) cookiesExpiring(d time.Duration) bool {
+	limit := time.Now().Add(d)
+	for _, cookies := range c.jar().GetAllCookies() {
+		for _, ck := range cookies {
+			if !slices.Contains(authCookies, ck.Name) {
+				continue
+			}
+			if !ck.Expires.IsZero() && ck.Expires.Before(limit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadSession restores the cookie jar from the session file. A missing
+// file is not an error.
+func (c *Client) loadSession() error {
+	raw, err := os.ReadFile(c.session.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read session: %w", err)
+	}
+	var f sessionFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return fmt.Errorf("decode session %s: %w", c.session.path, err)
+	}
+	now := time.Now()
+	live := f.Cookies[:0]
+	for _, ck := range f.Cookies {
+		if ck.Expires.IsZero() || ck.Expires.After(now) {
+			live = append(live, ck)
+		}
+	}
+	c.setCookies(live) // the user stays unknown until RefreshSession checks them
+	return nil
+}
+
+// saveSession writes the cookie jar to the session file, if there is
+// one. The file holds credentials in all but name, so it is created
+// readable by the owner only.
+func (c *Client) saveSession() error {
+	if c.session == nil || c.session.path == "" {
+		return nil
+	}
+	f := sessionFile{SavedAt: time.Now(), User: c.User()}
+	for host, cookies := range c.jar().GetAllCookies() {
+		for _, ck := range cookies {
+			f.Cookies = append(f.Cookies, savedCookie{
+				Host:     host,
+				Name:     ck.Name,
+				Value:    ck.Value,
+				Path:     ck.Path,
+				Expires:  ck.Expires,
+				Secure:   ck.Secure,
+				HTTPOnly: ck.HttpOnly,
+			})
+		}
+	}
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	if err := WriteFileAtomic(c.session.path, raw, 0o700); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	return nil
+}
+
+// parseJSONCookies reads the array format of browser cookie exporters.
+func parseJSONCookies(raw []byte) ([]savedCookie, error) {
+	var list []struct {
+		Domain         string  `json:"domain"`
+		Name           string  `json:"name"`
+		Value          string  `json:"value"`
+		Path           string  `json:"path"`
+		ExpirationDate float64 `json:"expirationDate"` // unix seconds
+		Secure         bool    `json:"secure"`
+		HTTPOnly       bool    `json:"httpOnly"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	var out []savedCookie
+	for _, c := range list {
+		host, ok := cookieHost(c.Domain)
+		if !ok {
+			continue
+		}
+		sc := savedCookie{Host: host, Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HTTPOnly: c.HTTPOnly}
+		if c.ExpirationDate > 0 {
+			sc.Expires = time.Unix(int64(c.ExpirationDate), 0)
+		}
+		out = append(out, sc)
+	}
+	return out, nil
+}
+
+// parseNetscapeCookies reads the tab-separated cookies.txt format.
+func parseNetscapeCookies(raw []byte) ([]savedCookie, error) {
+	var out []savedCookie
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 7 {
+			return nil, fmt.Errorf("line %d: want 7 tab-separated fields, got %d", n, len(f))
+		}
+		host, ok := cookieHost(f[0])
+		if !ok {
+			continue
+		}
+		c := savedCookie{Host: host, Path: f[2], Secure: f[3] == "TRUE", Name: f[5], Value: f[6], HTTPOnly: httpOnly}
+		if exp, err := strconv.ParseInt(f[4], 10, 64); err == nil && exp > 0 {
+			c.Expires = time.Unix(exp, 0)
+		}
+		out = append(out, c)
+	}
+	return out, sc.Err()
+}
+
+// cookieHost maps a cookie domain onto the jar key of BaseURL's site,
+// reporting false for cookies of other sites.
+func cookieHost(domain string) (string, bool) {
+	base, err := url.Parse(BaseURL)
+	if err != nil {
+		return "", false
+	}
+	site := jarKey(base.Host)
+	if jarKey(strings.TrimPrefix(domain, ".")) != site {
+		return "", false
+	}
+	return site, true
+}
+
+// jarKey mirrors how the cookie jar files cookies: by the last two labels
+// of a host name, or the whole host for anything else (e.g. localhost:8080).
+func jarKey(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) == 2 || len(parts) == 3 {
+		return strings.Join(parts[len(parts)-2:], ".")
+	}
+	return host
+}
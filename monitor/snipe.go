@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defaults for a Snipe's timing.
+const (
+	defaultSnipeWarmup = time.Minute
+	defaultSnipeBefore = 5 * time.Second
+	defaultSnipeAfter  = 2 * time.Minute
+	defaultSnipeEvery  = 750 * time.Millisecond
+	maxSnipeErrors     = 5 // consecutive failed burst polls before giving up
+)
+
+// ErrSnipeMissed is returned when a burst ends without a matching slot.
+var ErrSnipeMissed = errors.New("no matching slot during the release burst")
+
+// Snipe times a burst of polling around the moment a restaurant releases
+// its inventory. Zero durations take sensible defaults.
+type Snipe struct {
+	Release time.Time     // when the inventory drops
+	Warmup  time.Duration // refresh the session this long before the burst
+	Before  time.Duration // start bursting this long before Release
+	After   time.Duration // keep bursting this long after Release
+	Every   time.Duration // gap between burst polls
+
+	// Follow keeps watching on the watch's normal schedule after a burst
+	// that found nothing, instead of returning ErrSnipeMissed.
+	Follow bool
+}
+
+// ReleaseTime returns when the slots for date go live at a restaurant
+// that opens bookings daysAhead days in advance at hh:mm in zone tz
+// ("" = local), e.g. 30 days ahead at 10:00 America/New_York.
+func ReleaseTime(date string, daysAhead int, hhmm, tz string) (time.Time, error) {
+	loc, err := Schedule{TZ: tz}.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	d, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q: want YYYY-MM-DD", date)
+	}
+	m, err := clock(hhmm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("release time %q: want HH:MM", hhmm)
+	}
+	if daysAhead < 0 {
+		return time.Time{}, fmt.Errorf("days ahead must not be negative")
+	}
+	d = d.AddDate(0, 0, -daysAhead)
+	return time.Date(d.Year(), d.Month(), d.Day(), m/60, m%60, 0, 0, loc), nil
+}
+
+// Snipe waits for sn.Release, pre-warms the client shortly before it (a
+// fresh CSRF token and session, and one poll to open the connection and
+// learn the current slots), then polls w in a tight loop from sn.Before
+// ahead of the release until sn.After past it. The first match is
+// reported, and booked when w has a Booker, exactly as StartWatch would.
+func (c *Client) Snipe(ctx context.Context, w Watch, sn Snipe) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if sn.Release.IsZero() {
+		return fmt.Errorf("snipe: no release time")
+	}
+	warmup := cmp.Or(sn.Warmup, defaultSnipeWarmup)
+	before := cmp.Or(sn.Before, defaultSnipeBefore)
+	after := cmp.Or(sn.After, defaultSnipeAfter)
+	every := max(cmp.Or(sn.Every, defaultSnipeEvery), 100*time.Millisecond)
+
+	start, end := sn.Release.Add(-before), sn.Release.Add(after)
+	if time.Now().After(end) {
+		return fmt.Errorf("snipe: release at %s has already passed", sn.Release.Format(time.RFC3339))
+	}
+	fmt.Fprintf(Output, "🎯  Sniping %s — release at %s, bursting %s–%s\n",
+		w, sn.Release.Format("2006-01-02 15:04:05 MST"), start.Format("15:04:05"), end.Format("15:04:05"))
+
+	// pre-warm
+	if err := sleepUntil(ctx, start.Add(-warmup)); err != nil {
+		return err
+	}
+	fmt.Fprintln(Output, "🔥  Warming up…")
+	if c.session != nil {
+		if err := c.RefreshSession(ctx); err != nil {
+			fmt.Fprintf(Output, "⚠️  %v\n", err)
+		}
+	} else if err := c.refreshCSRF(ctx); err != nil {
+		fmt.Fprintf(Output, "⚠️  warm-up: %v\n", err)
+	}
+	tr := newSlotTracker()
+	if ok, _, err := c.pollWatch(ctx, w, tr); err != nil {
+		fmt.Fprintf(Output, "⚠️  warm-up poll: %v\n", err)
+	} else if ok {
+		return nil // already there, no need to wait for the drop
+	}
+
+	// burst
+	if err := sleepUntil(ctx, start); err != nil {
+		return err
+	}
+	fmt.Fprintf(Output, "⚡  Burst polling every %s…\n", every)
+	failures := 0
+	for polls := 1; time.Now().Before(end); polls++ {
+		t0 := time.Now()
+		ok, _, err := c.pollWatch(ctx, w, tr)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			failures++
+			fmt.Fprintf(Output, "⚠️  burst poll %d: %v\n", polls, err)
+			if failures == maxSnipeErrors {
+				return fmt.Errorf("snipe: %d polls in a row failed: %w", failures, err)
+			}
+		case ok:
+			fmt.Fprintf(Output, "🎯  Hit on poll %d, %s after the release\n",
+				polls, time.Since(sn.Release).Round(10*time.Millisecond))
+			return nil
+		default:
+			failures = 0
+		}
+		if err := sleepUntil(ctx, t0.Add(every)); err != nil {
+			return err
+		}
+	}
+
+	if !sn.Follow {
+		return ErrSnipeMissed
+	}
+	fmt.Fprintln(Output, "⌛  Burst over, back to normal polling")
+	return c.startWatch(ctx, w, tr) // same tracker: nothing seen in the burst is news
+}
+
+// sleepUntil blocks until t or until ctx ends.
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxHistory caps the notification history kept per watch.
+const maxHistory = 500
+
+// Store persists watch state so a restarted monitor resumes where it
+// left off instead of re-announcing every slot.
+type Store interface {
+	// Load returns every stored record keyed by watch ID.
+	Load() (map[string]WatchRecord, error)
+	// Save creates or replaces the record for rec.Watch.ID.
+	Save(rec WatchRecord) error
+	// Delete forgets the record of a watch.
+	Delete(id string) error
+}
+
+// WatchRecord is the persisted state of one watch.
+type WatchRecord struct {
+	Watch     Watch          `json:"watch"`
+	Status    WatchStatus    `json:"status"`
+	Seen      []Slot         `json:"seen"`     // last-seen slot set
+	Notified  []Notification `json:"notified"` // most recent last
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// Notification is one alert that was handed to a watch's callback.
+type Notification struct {
+	At        time.Time `json:"at"`
+	Exact     bool      `json:"exact"`
+	SlotHash  string    `json:"slotHash"`
+	Time      string    `json:"time"`
+	PartySize int       `json:"partySize,omitempty"`
+}
+
+// notified reports whether an alert for this slot and party size was
+// already sent. Entries written before sizes were recorded match any size.
+func (r WatchRecord) notified(exact bool, hash string, party int) bool {
+	for _, n := range r.Notified {
+		if n.SlotHash == hash && n.Exact == exact && (n.PartySize == 0 || n.PartySize == party) {
+			return true
+		}
+	}
+	return false
+}
+
+// FileStore is a Store backed by a single JSON file, rewritten
+// atomically on every change.
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	records map[string]WatchRecord
+}
+
+// NewFileStore opens (or creates on first save) the JSON state file.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, records: map[string]WatchRecord{}}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if len(raw) == 0 {
+		return s, nil
+	}
+	var list []WatchRecord
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decode state %s: %w", path, err)
+	}
+	for _, rec := range list {
+		s.records[rec.Watch.ID] = rec
+	}
+	return s, nil
+}
+
+// Load returns a copy of every record.
+func (s *FileStore) Load() (map[string]WatchRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]WatchRecord, len(s.records))
+	for id, rec := range s.records {
+		out[id] = rec
+	}
+	return out, nil
+}
+
+// Save stores rec and flushes the file.
+func (s *FileStore) Save(rec WatchRecord) error {
+	if len(rec.Notified) > maxHistory {
+		rec.Notified = rec.Notified[len(rec.Notified)-maxHistory:]
+	}
+	rec.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.Watch.ID] = rec
+	return s.flush()
+}
+
+// Delete removes the record of id and flushes the file.
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[id]; !ok {
+		return nil
+	}
+	delete(s.records, id)
+	return s.flush()
+}
+
+// flush writes every record to a temp file and renames it into place so
+// a crash mid-write never leaves a truncated state file. Caller holds s.mu.
+func (s *FileStore) flush() error {
+	list := make([]WatchRecord, 0, len(s.records))
+	for _, rec := range s.records {
+		list = append(list, rec)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Watch.ID < list[j].Watch.ID })
+
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	if err := WriteFileAtomic(s.path, raw, 0o755); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// WriteFileAtomic writes data to a temp file next to path, syncs it and
+// renames it into place, so a crash mid-write leaves either the old file
+// or the new one, never a truncated one. The file is readable by the
+// owner only; missing directories are created with dirPerm.
+func WriteFileAtomic(path string, data []byte, dirPerm fs.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*") // 0600
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Upgrade puts a watch in "beat my booking" mode: only slots that rank
+// better than the time already held, under the watch's own TimePref and
+// Match rules, count as matches. Worse slots are still tracked but never
+// announced.
+type Upgrade struct {
+	Current string `json:"current"` // HH:MM of the booking held now
+
+	// Reservation is the booking to move when the watch has a Booker. It
+	// may be nil when the baseline was typed in by hand; the watch then
+	// only notifies.
+	Reservation *Reservation `json:"reservation,omitempty"`
+}
+
+func (u *Upgrade) equal(o *Upgrade) bool {
+	if u == nil || o == nil {
+		return u == o
+	}
+	return u.Current == o.Current && (u.Reservation == nil) == (o.Reservation == nil) &&
+		(u.Reservation == nil || u.Reservation.ID == o.Reservation.ID)
+}
+
+// validateUpgrade checks the baseline of an upgrade watch.
+func (w Watch) validateUpgrade() error {
+	u := w.Upgrade
+	if u == nil {
+		return nil
+	}
+	if _, err := clock(u.Current); err != nil {
+		return inField("current", fmt.Errorf("current booking time %q: want HH:MM", u.Current))
+	}
+	if w.Booker != nil && u.Reservation == nil {
+		return inField("reservation", fmt.Errorf("moving a booking automatically needs the reservation itself, not just its time"))
+	}
+	return nil
+}
+
+// better keeps the hits that rank strictly better than current. A current
+// time the criteria don't match at all is beaten by every hit.
+func (mt matcher) better(hits []slotInfo, current string) []slotInfo {
+	base, ok := mt.rank(current)
+	if !ok {
+		return hits
+	}
+	return slices.DeleteFunc(slices.Clone(hits), func(s slotInfo) bool {
+		r, _ := mt.rank(s.Time)
+		return r.tier > base.tier || r.tier == base.tier && r.dist >= base.dist
+	})
+}
+
+// moveBest moves r to the best of hits that can be secured, trying them
+// in rank order like bookBest. Experiences are skipped.
+func (b *Booker) moveBest(ctx context.Context, r Reservation, hits []Slot) (*Booking, error) {
+	var errs []string
+	tried := 0
+	for _, s := range hits {
+		if tried == maxBookAttempts {
+			break
+		}
+		if s.Experience {
+			continue
+		}
+		tried++
+		if b.dryRun {
+			fmt.Fprintf(Output, "🧪  [dry run] would move #%s from %s to %s\n", r.ConfirmationNumber, r.Time, s.Time)
+			return &Booking{
+				RestaurantID:       s.RestaurantID,
+				Date:               s.Date,
+				Time:               s.Time,
+				PartySize:          s.PartySize,
+				ConfirmationNumber: r.ConfirmationNumber,
+				ReservationID:      r.ID,
+				DryRun:             true,
+			}, nil
+		}
+		bk, err := b.c.ModifyReservation(ctx, r, ReservationChange{Date: s.Date, Time: s.Time, PartySize: s.PartySize})
+		if err == nil {
+			return bk, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", s.Time, err))
+	}
+	if tried == 0 {
+		return nil, fmt.Errorf("no slot to move to (experiences must be booked by hand)")
+	}
+	return nil, fmt.Errorf("auto-modify failed: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,449 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Watch is one restaurant/date/time/party combination to keep an eye on.
+type Watch struct {
+	ID           string        `json:"id"`
+	RestaurantID string        `json:"restaurantId"`
+	Date         string        `json:"date"`
+	TimePref     string        `json:"time"`
+	PartySize    int           `json:"partySize"`
+	PartyMax     int           `json:"partyMax,omitempty"` // also accept up to this many; 0 = PartySize only
+	Interval     time.Duration `json:"interval,omitempty"` // 0 = the WatchList default
+	Schedule     Schedule      `json:"schedule,omitempty"` // jitter, fast windows, slow-downs
+	Retry        RetryPolicy   `json:"retry,omitempty"`    // backoff and alerting on failed polls
+	Group        string        `json:"group,omitempty"`    // ID of the date-range watch this date came from
+
+	// Match widens what counts as a hit beyond TimePref itself; Policy
+	// says whether to stop at the first hit or keep looking for better.
+	Match  TimeMatch   `json:"match,omitempty"`
+	Policy MatchPolicy `json:"policy,omitempty"`
+
+	// Filter drops unwanted seating before anything is matched or sent.
+	Filter SlotFilter `json:"filter,omitempty"`
+
+	// OnEvent is called when the preferred slot shows up or the set of
+	// alternatives changes. It may be nil.
+	OnEvent WatchCallback `json:"-"`
+
+	// Upgrade, when set, only counts slots better than a booking already
+	// held. With a Booker the booking is moved instead of a new one made.
+	Upgrade *Upgrade `json:"upgrade,omitempty"`
+
+	// Booker, when set, books the best match before it is announced.
+	Booker *Booker `json:"-"`
+}
+
+// WatchCallback receives the events of a single watch.
+type WatchCallback func(WatchEvent)
+
+// WatchEvent is what a poll reports back to the watch's callback.
+type WatchEvent struct {
+	Watch        Watch
+	Exact        bool     // true when a slot matching the watch is open
+	Time         string   // best-ranked matched time when Exact
+	PartySize    int      // party size of that match
+	URL          string   // booking link (best match, or first new alternative)
+	Alternatives []string // formatted "• 19:30 [bar] → [Book](…)" lines
+	Slots        []Slot   // every match best first, or the new alternatives
+	Booking      *Booking // set when the watch's Booker reserved a match
+	BookErr      error    // set when auto-booking was attempted and failed
+	Health       *Health  // set when the watch becomes degraded or recovers
+	Err          error    // set when the watch failed and stopped polling
+}
+
+// WatchStatus is the lifecycle state of a watch inside a WatchList.
+type WatchStatus string
+
+const (
+	WatchPending WatchStatus = "pending"
+	WatchRunning WatchStatus = "running"
+	WatchFound   WatchStatus = "found"
+	WatchFailed  WatchStatus = "failed"
+	WatchStopped WatchStatus = "stopped"
+	WatchExpired WatchStatus = "expired" // the date passed without a match
+)
+
+// WatchState is a point-in-time snapshot of a watch's progress.
+type WatchState struct {
+	Watch    Watch
+	Status   WatchStatus
+	Polls    int
+	Slots    int // slots seen on the last successful poll
+	LastPoll time.Time
+	LastErr  error
+	Failures int  // failed polls in a row
+	Degraded bool // Failures reached the watch's alert threshold
+}
+
+// FieldError is a Watch validation problem with the field it is about,
+// named by its JSON path (e.g. "schedule.quiet"), so callers that build
+// watches from their own input can point at the setting responsible.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string { return e.Err.Error() }
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// inField attributes err to field, nesting any field err already names.
+// Joined errors are attributed one by one.
+func inField(field string, err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *FieldError:
+		return &FieldError{Field: field + "." + e.Field, Err: e.Err}
+	case interface{ Unwrap() []error }:
+		var errs []error
+		for _, err := range e.Unwrap() {
+			errs = append(errs, inField(field, err))
+		}
+		return errors.Join(errs...)
+	}
+	return &FieldError{Field: field, Err: err}
+}
+
+// Validate checks everything about w that can be checked before it is
+// polled. Every way of running a watch calls it first. All problems are
+// reported, joined, each as a *FieldError.
+func (w Watch) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		err = inField(field, err)
+		if j, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = append(errs, j.Unwrap()...)
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if w.RestaurantID == "" {
+		check("restaurantId", errors.New("restaurant is required"))
+	} else if _, err := strconv.Atoi(w.RestaurantID); err != nil {
+		check("restaurantId", fmt.Errorf("restaurant id %q: %w", w.RestaurantID, err))
+	}
+	if w.Date == "" {
+		check("date", errors.New("date is required"))
+	} else if _, err := time.Parse("2006-01-02", w.Date); err != nil {
+		check("date", fmt.Errorf("date %q: want YYYY-MM-DD", w.Date))
+	}
+	if w.TimePref == "" {
+		check("time", errors.New("time is required"))
+	} else if _, err := clock(w.TimePref); err != nil {
+		check("time", fmt.Errorf("time %q: want HH:MM", w.TimePref))
+	}
+	if w.PartySize <= 0 {
+		check("partySize", errors.New("party size must be at least 1"))
+	}
+	check("partyMax", CheckPartyRange(w.PartySize, w.PartyMax))
+	if w.Interval < 0 {
+		check("interval", errors.New("interval must not be negative"))
+	}
+	switch w.Policy {
+	case "", PolicyStop, PolicyContinue:
+	default:
+		check("policy", fmt.Errorf("unknown policy %q (want stop or continue)", w.Policy))
+	}
+	check("match", w.Match.validate())
+	check("filter", w.Filter.Validate())
+	check("upgrade", w.validateUpgrade())
+	check("schedule", w.Schedule.validate())
+	check("retry", w.Retry.Validate())
+	return errors.Join(errs...)
+}
+
+// String returns a short human label for logs and terminal output.
+func (w Watch) String() string {
+	pref := w.TimePref
+	if m := w.Match.String(); m != "" {
+		pref += "; " + m
+	}
+	if f := w.Filter.String(); f != "" {
+		pref += "; " + f
+	}
+	if w.Upgrade != nil {
+		pref += "; beating " + w.Upgrade.Current
+	}
+	if sc := w.Schedule.String(); sc != "" {
+		pref += "; " + sc
+	}
+	return fmt.Sprintf("%s on %s (%s, party %s)",
+		w.RestaurantID, w.Date, pref, w.partyLabel())
+}
+
+// PartySizes lists the sizes the watch accepts, preferred (PartySize) first.
+func (w Watch) PartySizes() []int {
+	sizes := []int{w.PartySize}
+	for n := w.PartySize + 1; n <= w.PartyMax; n++ {
+		sizes = append(sizes, n)
+	}
+	return sizes
+}
+
+func (w Watch) partyLabel() string {
+	if w.PartyMax > w.PartySize {
+		return fmt.Sprintf("%d-%d", w.PartySize, w.PartyMax)
+	}
+	return strconv.Itoa(w.PartySize)
+}
+
+// Origin is the ID the watch was registered under: the range watch's ID
+// for one of its dates, otherwise its own ID.
+func (w Watch) Origin() string {
+	if w.Group != "" {
+		return w.Group
+	}
+	return w.ID
+}
+
+// sameTarget reports whether two watches look for the same thing, so
+// stored state is only reused when the watch itself is unchanged.
+func (w Watch) sameTarget(o Watch) bool {
+	return w.RestaurantID == o.RestaurantID && w.Date == o.Date &&
+		w.TimePref == o.TimePref && w.PartySize == o.PartySize && w.PartyMax == o.PartyMax &&
+		w.Match.equal(o.Match) && w.Policy == o.Policy && w.Filter.equal(o.Filter) &&
+		w.Upgrade.equal(o.Upgrade)
+}
+
+// slotTracker remembers the last-seen slot set of one watch so each poll
+// only reports what changed.
+type slotTracker struct {
+	prev  map[string]slotInfo // key = slotInfo.key()
+	token string              // availability token of the last poll
+	best  string              // key of the last reported best match
+}
+
+// seen exports the last-seen slot set for persistence.
+func (tr *slotTracker) seen(w Watch, rid int) []Slot {
+	out := make([]Slot, 0, len(tr.prev))
+	for _, s := range tr.prev {
+		out = append(out, s.export(rid, w.Date, w.PartySize, tr.token))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time < out[j].Time })
+	return out
+}
+
+// restore seeds the tracker from a persisted slot set.
+func (tr *slotTracker) restore(seen []Slot) {
+	for _, s := range seen {
+		si := s.info()
+		tr.prev[si.key()] = si
+	}
+}
+
+func newSlotTracker() *slotTracker {
+	return &slotTracker{prev: map[string]slotInfo{}}
+}
+
+// pollWatch runs a single availability check for w, prints the diff
+// against tr and fires w.OnEvent. It returns true once a matching slot
+// is open and the watch's policy says to stop. The second return value
+// is the number of slots currently open.
+func (c *Client) pollWatch(ctx context.Context, w Watch, tr *slotTracker) (bool, int, error) {
+	ctx = withProxyKey(ctx, w.Origin())
+	var current []slotInfo
+	var token string
+	var rid int
+	for _, party := range w.PartySizes() {
+		slots, tok, id, err := c.fetchSlots(ctx, w.RestaurantID, w.Date, w.TimePref, party)
+		if err != nil {
+			return false, 0, err
+		}
+		current = append(current, slots...)
+		if id != 0 {
+			rid = id
+		}
+		if token == "" {
+			token = tok
+		}
+	}
+	return tr.apply(ctx, w, current, token, rid), len(w.Filter.apply(current)), nil
+}
+
+// apply diffs current against the last-seen slot set, prints what changed
+// and fires w.OnEvent. Matching slots are reported first, best-ranked
+// first; it returns true when one is open and w.Policy is to stop. ctx
+// bounds any auto-book the match sets off.
+func (tr *slotTracker) apply(ctx context.Context, w Watch, current []slotInfo, token string, rid int) bool {
+	tr.token = token
+	current = w.Filter.apply(current)
+
+	// mapify current for quick lookup
+	now := make(map[string]slotInfo, len(current))
+	for _, s := range current {
+		now[s.key()] = s
+	}
+
+	// any slot matching the watch's criteria (and beating the booking
+	// held, in upgrade mode)?
+	mt := newMatcher(w)
+	hits := mt.matches(current)
+	if w.Upgrade != nil {
+		hits = mt.better(hits, w.Upgrade.Current)
+	}
+	matched := make(map[string]bool, len(hits))
+	for _, s := range hits {
+		matched[s.key()] = true
+	}
+	if len(hits) > 0 && hits[0].key() != tr.best {
+		best := hits[0]
+		tr.best = best.key()
+		party := best.Party
+		if party == 0 {
+			party = w.PartySize
+		}
+		if w.Upgrade != nil {
+			fmt.Fprintf(Output, "\n⬆️  Better slot FOUND — %s at %s instead of %s, party %d", w.Date, best.Time, w.Upgrade.Current, party)
+		} else {
+			fmt.Fprintf(Output, "\n🎉  Matching slot FOUND — %s at %s, party %d", w.Date, best.Time, party)
+		}
+		if len(hits) > 1 {
+			fmt.Fprintf(Output, " (best of %d)", len(hits))
+		}
+		fmt.Fprintln(Output)
+
+		slots := make([]Slot, len(hits))
+		for i, s := range hits {
+			slots[i] = s.export(rid, w.Date, w.PartySize, token)
+		}
+		url := best.buildURL(w.Date, w.PartySize, token, rid)
+		booking, bookErr := autoBook(ctx, w, slots)
+		if bookErr != nil {
+			fmt.Fprintf(Output, "⚠️  [%s] %v\n", w.RestaurantID, bookErr)
+		}
+		matchTime := best.Time
+		if booking != nil { // may be a runner-up if the best was taken
+			matchTime, party = booking.Time, booking.PartySize
+			for _, s := range slots {
+				if s.Time == booking.Time && s.PartySize == booking.PartySize {
+					url = s.URL
+					break
+				}
+			}
+		}
+
+		if w.OnEvent != nil {
+			w.OnEvent(WatchEvent{
+				Watch:     w,
+				Exact:     true,
+				Time:      matchTime,
+				PartySize: party,
+				URL:       url,
+				Slots:     slots,
+				Booking:   booking,
+				BookErr:   bookErr,
+			})
+		}
+		if booking != nil {
+			return true // booked: never book twice, whatever the policy
+		}
+	}
+	if len(hits) > 0 && w.Policy != PolicyContinue {
+		return true
+	}
+	if len(hits) == 0 {
+		tr.best = ""
+	}
+
+	added := []slotInfo{}
+	for h, s := range now {
+		if _, seen := tr.prev[h]; !seen {
+			added = append(added, s)
+		}
+	}
+
+	removed := []slotInfo{}
+	for h, s := range tr.prev {
+		if _, still := now[h]; !still {
+			removed = append(removed, s)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		// nothing changed -> stay silent
+		return false
+	}
+
+	// Handle changes
+	if len(tr.prev) == 0 {
+		fmt.Fprintf(Output, "\n⏰  [%s] Preferred %s unavailable — %d alternative time(s):\n",
+			w.RestaurantID, w.TimePref, len(current))
+	}
+
+	// matches were already announced above
+	added = slices.DeleteFunc(added, func(s slotInfo) bool { return matched[s.key()] })
+
+	if len(added) > 0 {
+		if len(tr.prev) != 0 { // skip label for first big print
+			fmt.Fprintf(Output, "\n➕  [%s] %d new slot(s):\n", w.RestaurantID, len(added))
+		}
+
+		sort.Slice(added, func(i, j int) bool {
+			if added[i].Time != added[j].Time {
+				return added[i].Time < added[j].Time
+			}
+			return added[i].Party < added[j].Party
+		})
+
+		reservationURL := "" // the first URL (for “Book Now”)
+		alternativeTimes := make([]string, 0, len(added))
+		slots := make([]Slot, 0, len(added))
+
+		for _, s := range added {
+			attr := strings.Join(s.Attributes, ",")
+			size := "" // tag the party size when the watch accepts several
+			if w.PartyMax > w.PartySize && s.Party > 0 {
+				size = fmt.Sprintf(" (party %d)", s.Party)
+			}
+			url := s.buildURL(w.Date, w.PartySize, token, rid)
+
+			// terminal output
+			fmt.Fprintf(Output, "   • %s [%s]%s → %s\n", s.Time, attr, size, url)
+
+			// discord output
+			alternativeTimes = append(alternativeTimes,
+				fmt.Sprintf("• %s [%s]%s → [Book](%s)", s.Time, attr, size, url))
+			slots = append(slots, s.export(rid, w.Date, w.PartySize, token))
+
+			if reservationURL == "" {
+				reservationURL = url
+			}
+		}
+
+		// forward to caller (Discord) if requested; an upgrade watch
+		// only speaks up for slots that beat the booking
+		if w.OnEvent != nil && len(alternativeTimes) > 0 && w.Upgrade == nil {
+			w.OnEvent(WatchEvent{
+				Watch:        w,
+				URL:          reservationURL,
+				Alternatives: alternativeTimes,
+				Slots:        slots,
+			})
+		}
+	}
+
+	if len(removed) > 0 {
+		fmt.Fprintf(Output, "\n➖  [%s] %d slot(s) disappeared:\n", w.RestaurantID, len(removed))
+		for _, s := range removed {
+			attr := strings.Join(s.Attributes, ",")
+			fmt.Fprintf(Output, "   • %s [%s] (slotHash %s)\n",
+				s.Time, attr, s.SlotHash)
+		}
+	}
+
+	// update cache
+	tr.prev = now
+	return false
+}
@@ -0,0 +1,674 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Batching limits for a single RestaurantsAvailability call.
+const (
+	maxForwardDays      = 6  // widest date window per request
+	maxBatchRestaurants = 20 // restaurantIds per request
+)
+
+// maxPartySize is the largest party OpenTable books online.
+const maxPartySize = 20
+
+// CheckPartyRange reports whether max (0 = none) widens party to a range
+// OpenTable can book.
+func CheckPartyRange(party, max int) error {
+	if max != 0 && (max < party || max > maxPartySize) {
+		return fmt.Errorf("party range %d-%d must be ascending and at most %d", party, max, maxPartySize)
+	}
+	return nil
+}
+
+// WatchList runs many watches on one Client so they all share its CSRF
+// token and cookie jar. Watches that can share a request (same time and
+// party size) are polled with one batched availability call, so a large
+// list costs one request per poll instead of one per watch and date.
+// Watches can be added or removed while the list is running.
+type WatchList struct {
+	c        *Client
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*watchEntry
+	running bool
+	wake    chan struct{} // nudges Run when a watch is added
+
+	store Store                  // nil = in-memory only
+	saved map[string]WatchRecord // records loaded from store
+
+	groups map[string]*watchGroup // date-range watches by ID
+	today  string                 // date groups were last expanded for
+}
+
+// watchGroup is a watch over a DateSpec; each concrete date runs as its
+// own entry with Watch.Group set to the group's ID.
+type watchGroup struct {
+	base Watch
+	spec DateSpec
+	done bool // a date matched under PolicyStop; add no more dates
+}
+
+// watchEntry is one watch of the list. tr belongs to the goroutine
+// running the list; everything else is guarded by wl.mu.
+type watchEntry struct {
+	state   WatchState
+	rid     int
+	tr      *slotTracker
+	seen    []Slot         // tr's slot set as of the last recorded poll, for persist
+	history []Notification // alerts already delivered
+	resumed bool           // restored from store, first poll still pending
+	next    time.Time      // when this watch is due for its next poll
+	health  health         // current run of failed polls
+	removed bool
+}
+
+// NewWatchList returns an empty list that polls every watch once a minute.
+func (c *Client) NewWatchList() *WatchList {
+	return &WatchList{
+		c:        c,
+		interval: 60 * time.Second,
+		entries:  map[string]*watchEntry{},
+		wake:     make(chan struct{}, 1),
+		groups:   map[string]*watchGroup{},
+	}
+}
+
+// SetInterval changes the default poll interval for watches that don't
+// set their own.
+func (wl *WatchList) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	wl.mu.Lock()
+	wl.interval = d
+	wl.mu.Unlock()
+}
+
+// UseStore makes the list persist each watch's status, last-seen slots
+// and notification history in s. Watches added afterwards whose ID has a
+// stored record resume from it, so a restart neither re-announces known
+// slots nor re-sends alerts. Call it before Add.
+func (wl *WatchList) UseStore(s Store) error {
+	recs, err := s.Load()
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	wl.store = s
+	wl.saved = recs
+	return nil
+}
+
+// Add registers w. When the list is already running the watch is polled
+// straight away. An empty ID is derived from the watch itself.
+func (wl *WatchList) Add(w Watch) error {
+	if err := w.Validate(); err != nil {
+		return fmt.Errorf("watch %q: %w", w.ID, err)
+	}
+	rid, _ := strconv.Atoi(w.RestaurantID)
+	if w.ID == "" {
+		w.ID = fmt.Sprintf("%s-%s-%s-%d", w.RestaurantID, w.Date, w.TimePref, w.PartySize)
+	}
+
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	if _, dup := wl.entries[w.ID]; dup {
+		return fmt.Errorf("watch %q already exists", w.ID)
+	}
+	e := &watchEntry{
+		state: WatchState{Watch: w, Status: WatchPending},
+		rid:   rid,
+		tr:    newSlotTracker(),
+	}
+	if cb := w.OnEvent; cb != nil {
+		e.state.Watch.OnEvent = wl.deliver(e, cb)
+	}
+	if rec, ok := wl.saved[w.ID]; ok && rec.Watch.sameTarget(w) {
+		e.tr.restore(rec.Seen)
+		e.seen = rec.Seen
+		e.history = rec.Notified
+		e.resumed = true
+		if rec.Status == WatchFound {
+			e.state.Status = WatchFound
+			fmt.Fprintf(Output, "✔️  [%s] already found before restart — skipping\n", w.ID)
+			if w.Group != "" && w.Policy != PolicyContinue {
+				wl.finishGroup(w)
+			}
+		}
+	}
+	wl.entries[w.ID] = e
+	wl.persist(e)
+
+	if wl.running {
+		wl.start(e)
+		wl.nudge()
+	}
+	return nil
+}
+
+// AddRange registers w for every date spec covers. Each date becomes
+// its own watch with ID "<w.ID>-<date>" and Group w.ID; past dates are
+// skipped, and rolling specs gain new dates as the days go by. With
+// PolicyStop the whole range finishes once any date matches.
+func (wl *WatchList) AddRange(w Watch, spec DateSpec) error {
+	if err := spec.validate(); err != nil {
+		return fmt.Errorf("watch %q: %w", w.ID, err)
+	}
+	if w.ID == "" {
+		w.ID = fmt.Sprintf("%s-%s-%d", w.RestaurantID, w.TimePref, w.PartySize)
+	}
+	wl.mu.Lock()
+	if _, dup := wl.groups[w.ID]; dup {
+		wl.mu.Unlock()
+		return fmt.Errorf("watch %q already exists", w.ID)
+	}
+	g := &watchGroup{base: w, spec: spec}
+	wl.groups[w.ID] = g
+	wl.mu.Unlock()
+
+	n, err := wl.expand(g, time.Now())
+	if err == nil && n == 0 {
+		err = fmt.Errorf("watch %q: %s has no upcoming dates", w.ID, spec)
+	}
+	if err != nil {
+		wl.mu.Lock()
+		delete(wl.groups, w.ID)
+		wl.mu.Unlock()
+		wl.removeGroup(w.ID)
+	}
+	return err
+}
+
+// expand adds a watch for every date of g not yet registered and
+// reports how many dates g currently covers.
+func (wl *WatchList) expand(g *watchGroup, now time.Time) (int, error) {
+	dates := g.spec.Expand(now)
+	for _, d := range dates {
+		w := g.base
+		w.ID, w.Date, w.Group = g.base.ID+"-"+d, d, g.base.ID
+
+		wl.mu.Lock()
+		_, exists := wl.entries[w.ID]
+		done := g.done
+		wl.mu.Unlock()
+		if exists || done {
+			continue
+		}
+		if err := wl.Add(w); err != nil {
+			return 0, err
+		}
+	}
+	return len(dates), nil
+}
+
+// Remove stops and forgets the watch with the given ID, or every date
+// of the range watch with that ID. It reports whether anything existed.
+func (wl *WatchList) Remove(id string) bool {
+	wl.mu.Lock()
+	_, isGroup := wl.groups[id]
+	delete(wl.groups, id)
+	wl.mu.Unlock()
+	if isGroup {
+		wl.removeGroup(id)
+		return true
+	}
+
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	return wl.remove(id)
+}
+
+// removeGroup removes every entry belonging to the group id.
+func (wl *WatchList) removeGroup(id string) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+	for eid, e := range wl.entries {
+		if e.state.Watch.Group == id {
+			wl.remove(eid)
+		}
+	}
+}
+
+// remove drops one entry. Caller holds wl.mu.
+func (wl *WatchList) remove(id string) bool {
+	e, ok := wl.entries[id]
+	if !ok {
+		return false
+	}
+	e.removed = true
+	e.state.Status = WatchStopped
+	delete(wl.entries, id)
+	wl.nudge()
+	if wl.store != nil {
+		if err := wl.store.Delete(id); err != nil {
+			fmt.Fprintf(Output, "⚠️  [%s] forget state: %v\n", id, err)
+		}
+	}
+	return true
+}
+
+// Watches returns a snapshot of every watch, ordered by ID.
+func (wl *WatchList) Watches() []WatchState {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	out := make([]WatchState, 0, len(wl.entries))
+	for _, e := range wl.entries {
+		out = append(out, e.state)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Watch.ID < out[j].Watch.ID })
+	return out
+}
+
+// Run polls every registered watch and blocks until ctx is cancelled or
+// no watch is left polling (all found, failed or removed).
+func (wl *WatchList) Run(ctx context.Context) error {
+	wl.mu.Lock()
+	if wl.running {
+		wl.mu.Unlock()
+		return fmt.Errorf("watch list already running")
+	}
+	wl.running = true
+	for _, e := range wl.entries {
+		wl.start(e)
+	}
+	wl.mu.Unlock()
+
+	defer func() {
+		wl.mu.Lock()
+		wl.running = false
+		for _, e := range wl.entries {
+			if e.state.Status == WatchRunning {
+				e.state.Status = WatchStopped
+			}
+		}
+		wl.mu.Unlock()
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wl.rollover(time.Now())
+		wl.c.maybeRefreshSession(ctx)
+		due, wait, active := wl.due(time.Now())
+		if !active {
+			return nil
+		}
+		if paused := wl.c.breaker.wait(time.Now()); paused > 0 {
+			due, wait = nil, paused
+		}
+		if len(due) > 0 {
+			wl.pollBatches(ctx, due)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-wl.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// rollover runs once per calendar day: it expires watches whose date
+// has passed and expands range watches so new dates join.
+func (wl *WatchList) rollover(now time.Time) {
+	today := now.Format("2006-01-02")
+	wl.mu.Lock()
+	if wl.today == today {
+		wl.mu.Unlock()
+		return
+	}
+	wl.today = today
+	for _, e := range wl.entries {
+		st := e.state.Status
+		if e.state.Watch.Date < today && (st == WatchPending || st == WatchRunning) {
+			e.state.Status = WatchExpired
+			fmt.Fprintf(Output, "⌛  [%s] %s has passed — no longer watching\n", e.state.Watch.ID, e.state.Watch.Date)
+			wl.persist(e)
+		}
+	}
+	groups := make([]*watchGroup, 0, len(wl.groups))
+	for _, g := range wl.groups {
+		groups = append(groups, g)
+	}
+	wl.mu.Unlock()
+
+	for _, g := range groups {
+		if _, err := wl.expand(g, now); err != nil {
+			fmt.Fprintf(Output, "⚠️  [%s] add new dates: %v\n", g.base.ID, err)
+		}
+	}
+}
+
+// start marks a pending entry as running and due now. Caller holds wl.mu.
+func (wl *WatchList) start(e *watchEntry) {
+	if e.state.Status != WatchPending {
+		return
+	}
+	if e.state.Watch.Date < time.Now().Format("2006-01-02") {
+		e.state.Status = WatchExpired
+		fmt.Fprintf(Output, "⌛  [%s] %s has passed — skipping\n", e.state.Watch.ID, e.state.Watch.Date)
+		wl.persist(e)
+		return
+	}
+	e.state.Status = WatchRunning
+	e.next = time.Now()
+	fmt.Fprintf(Output, "🔎  Watching %s…\n", e.state.Watch)
+}
+
+// nudge wakes Run without blocking. Caller holds wl.mu.
+func (wl *WatchList) nudge() {
+	select {
+	case wl.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due returns the running watches whose poll time has come, how long to
+// sleep until the next one, and whether any watch is still running.
+func (wl *WatchList) due(now time.Time) ([]*watchEntry, time.Duration, bool) {
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	var due []*watchEntry
+	wait := wl.interval
+	active := false
+	for _, e := range wl.entries {
+		if e.state.Status != WatchRunning {
+			continue
+		}
+		active = true
+		if !e.next.After(now) {
+			due = append(due, e)
+		} else if d := e.next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return due, wait, active
+}
+
+// watchBatch is a group of watches served by one availability request.
+type watchBatch struct {
+	query   AvailabilityQuery
+	entries []*watchEntry
+}
+
+// planBatches groups entries by time and party size, then splits each
+// group so no request spans more than maxForwardDays or names more than
+// maxBatchRestaurants restaurants. A watch over a range of party sizes
+// joins one group per size, sharing those requests with other watches.
+func planBatches(entries []*watchEntry) []watchBatch {
+	type key struct {
+		time  string
+		party int
+	}
+	groups := map[key][]*watchEntry{}
+	var order []key
+	for _, e := range entries {
+		for _, party := range e.state.Watch.PartySizes() {
+			k := key{e.state.Watch.TimePref, party}
+			if _, ok := groups[k]; !ok {
+				order = append(order, k)
+			}
+			groups[k] = append(groups[k], e)
+		}
+	}
+
+	var batches []watchBatch
+	for _, k := range order {
+		group := groups[k]
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].state.Watch.Date < group[j].state.Watch.Date
+		})
+
+		var cur *watchBatch
+		var start time.Time
+		rids := map[int]bool{}
+		for _, e := range group {
+			d, _ := time.Parse("2006-01-02", e.state.Watch.Date)
+			span := int(d.Sub(start).Hours() / 24)
+			if cur == nil || span > maxForwardDays ||
+				(!rids[e.rid] && len(rids) >= maxBatchRestaurants) {
+				batches = append(batches, watchBatch{query: AvailabilityQuery{
+					Date:      e.state.Watch.Date,
+					Time:      k.time,
+					PartySize: k.party,
+				}})
+				cur = &batches[len(batches)-1]
+				start, span = d, 0
+				rids = map[int]bool{}
+			}
+			if !rids[e.rid] {
+				rids[e.rid] = true
+				cur.query.RestaurantIDs = append(cur.query.RestaurantIDs, e.rid)
+			}
+			cur.query.ForwardDays = span
+			cur.entries = append(cur.entries, e)
+		}
+	}
+	return batches
+}
+
+// pollBatches runs one availability request per batch and feeds each
+// watch its own slice of the results, merged across party sizes.
+func (wl *WatchList) pollBatches(ctx context.Context, due []*watchEntry) {
+	type result struct {
+		slots   []slotInfo
+		token   string
+		err     error
+		skipped bool // the circuit opened before its request went out
+	}
+	results := make(map[*watchEntry]*result, len(due))
+	for _, e := range due {
+		results[e] = &result{}
+	}
+
+	for _, b := range planBatches(due) {
+		if wl.c.breaker.wait(time.Now()) > 0 {
+			for _, e := range b.entries {
+				results[e].skipped = true // still due once the circuit closes
+			}
+			continue
+		}
+		// a shared request goes out through its first watch's proxy
+		raw, err := wl.c.fetchAvailability(withProxyKey(ctx, b.entries[0].state.Watch.Origin()), b.query)
+		for _, e := range b.entries {
+			r := results[e]
+			if err != nil {
+				r.err = err
+				continue
+			}
+			ra := raw[e.rid]
+			r.slots = append(r.slots, ra.days[e.state.Watch.Date]...)
+			if r.token == "" {
+				r.token = ra.token
+			}
+		}
+	}
+
+	for _, e := range due {
+		wl.mu.Lock()
+		w, removed := e.state.Watch, e.removed
+		wl.mu.Unlock()
+		if removed {
+			continue
+		}
+
+		r := results[e]
+		if r.skipped {
+			continue
+		}
+		var found bool
+		var n int
+		if r.err == nil {
+			n = len(w.Filter.apply(r.slots))
+			found = e.tr.apply(withProxyKey(ctx, w.Origin()), w, r.slots, r.token, e.rid)
+		}
+		wl.record(ctx, e, found, n, r.err)
+	}
+}
+
+// record stores the outcome of one poll on e and schedules the next one.
+// A failed poll is retried with backoff under the watch's RetryPolicy;
+// only a fatal error or running out of retries fails the watch.
+func (wl *WatchList) record(ctx context.Context, e *watchEntry, found bool, n int, err error) {
+	wl.mu.Lock()
+	e.state.Polls++
+	e.state.LastPoll = time.Now()
+	e.state.LastErr = err
+	e.seen = e.tr.seen(e.state.Watch, e.rid)
+	var backoff time.Duration
+	var change *Health
+	giveUp := false
+	switch {
+	case err == nil:
+		e.state.Slots = n
+		e.resumed = false
+		change = e.health.ok()
+	case ctx.Err() == nil:
+		backoff, change, giveUp = e.health.fail(e.state.Watch.Retry, err, e.state.LastPoll)
+	}
+	e.state.Failures, e.state.Degraded = e.health.failures, e.health.degraded
+	if !e.removed {
+		switch {
+		case ctx.Err() != nil:
+			e.state.Status = WatchStopped
+		case giveUp:
+			e.state.Status = WatchFailed
+		case found:
+			e.state.Status = WatchFound
+		}
+	}
+	interval := wl.interval
+	if e.state.Watch.Interval > 0 {
+		interval = e.state.Watch.Interval
+	}
+	e.next = e.state.Watch.Schedule.next(e.state.LastPoll, interval, e.state.Watch.Date)
+	if err != nil {
+		e.next = e.state.LastPoll.Add(backoff)
+	}
+	w, status, failures := e.state.Watch, e.state.Status, e.state.Failures
+	if !e.removed {
+		wl.persist(e)
+	}
+	if status == WatchFound && w.Group != "" && w.Policy != PolicyContinue {
+		wl.finishGroup(w)
+	}
+	wl.mu.Unlock()
+
+	switch {
+	case status == WatchFailed:
+		fmt.Fprintf(Output, "⚠️  [%s] poll failed, giving up: %v\n", w.ID, err)
+		if w.OnEvent != nil {
+			w.OnEvent(WatchEvent{Watch: w, Err: err})
+		}
+	case err != nil && status == WatchRunning:
+		reportFailure(w, failures, backoff, err, change)
+	default:
+		reportHealth(w, change)
+	}
+}
+
+// finishGroup stops the other dates of w's range once w has found its
+// match. Caller holds wl.mu.
+func (wl *WatchList) finishGroup(w Watch) {
+	if g, ok := wl.groups[w.Group]; ok {
+		g.done = true
+	}
+	for _, o := range wl.entries {
+		st := o.state.Status
+		if o.state.Watch.Group != w.Group || o.state.Watch.ID == w.ID ||
+			(st != WatchPending && st != WatchRunning) {
+			continue
+		}
+		o.state.Status = WatchStopped
+		wl.persist(o)
+	}
+	fmt.Fprintf(Output, "✔️  [%s] matched on %s — stopping the other dates\n", w.Group, w.Date)
+}
+
+// deliver wraps a watch callback so every delivered alert lands in the
+// watch's history. On the first poll after a restart, slots that were
+// already announced before the restart are dropped; later on the slot
+// tracker alone decides, so a slot that vanishes and returns is news.
+func (wl *WatchList) deliver(e *watchEntry, cb WatchCallback) WatchCallback {
+	return func(ev WatchEvent) {
+		if ev.Err != nil || ev.Health != nil || ev.Booking != nil || ev.BookErr != nil {
+			cb(ev) // always news, even right after a restart
+			return
+		}
+
+		wl.mu.Lock()
+		var slots []Slot
+		var alts []string
+		for i, s := range ev.Slots {
+			if e.resumed && e.notified(ev.Exact, s.SlotHash, s.PartySize) {
+				continue
+			}
+			slots = append(slots, s)
+			if i < len(ev.Alternatives) {
+				alts = append(alts, ev.Alternatives[i])
+			}
+			e.history = append(e.history, Notification{
+				At:        time.Now(),
+				Exact:     ev.Exact,
+				SlotHash:  s.SlotHash,
+				Time:      s.Time,
+				PartySize: s.PartySize,
+			})
+		}
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+		if len(slots) > 0 {
+			wl.persist(e) // record before sending: a crash now can't cause a repeat
+		}
+		wl.mu.Unlock()
+
+		if len(slots) == 0 {
+			return // everything here was already announced
+		}
+		if !ev.Exact {
+			ev.Slots, ev.Alternatives, ev.URL = slots, alts, slots[0].URL
+		}
+		cb(ev)
+	}
+}
+
+// notified reports whether this slot was already announced.
+func (e *watchEntry) notified(exact bool, hash string, party int) bool {
+	return WatchRecord{Notified: e.history}.notified(exact, hash, party)
+}
+
+// persist saves e to the store, if any. Caller holds wl.mu. It never
+// touches e.tr, which Run may be updating on another goroutine.
+func (wl *WatchList) persist(e *watchEntry) {
+	if wl.store == nil {
+		return
+	}
+	rec := WatchRecord{
+		Watch:    e.state.Watch,
+		Status:   e.state.Status,
+		Seen:     e.seen,
+		Notified: e.history,
+	}
+	if err := wl.store.Save(rec); err != nil {
+		fmt.Fprintf(Output, "⚠️  [%s] save state: %v\n", e.state.Watch.ID, err)
+	}
+}
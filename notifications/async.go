@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// asyncBacklog is how many events an Async holds before dropping new ones.
+const asyncBacklog = 256
+
+// Async hands events to a notifier on a goroutine of its own, so a slow
+// or retrying backend never holds up the watch that raised them. Events
+// go out one at a time, in order.
+type Async struct {
+	n       Notifier
+	onErr   func(Event, error)
+	events  chan Event
+	pending sync.WaitGroup
+}
+
+// NewAsync starts delivering to n in the background. onErr, if non-nil,
+// is called with every event n failed to deliver.
+func NewAsync(n Notifier, onErr func(Event, error)) *Async {
+	a := &Async{n: n, onErr: onErr, events: make(chan Event, asyncBacklog)}
+	go a.run()
+	return a
+}
+
+// String names the wrapped backend in aggregated errors.
+func (a *Async) String() string { return name(a.n) }
+
+// Notify queues ev and returns at once. It only fails when the backlog
+// is full, which means the backend has stopped keeping up.
+func (a *Async) Notify(ev Event) error {
+	a.pending.Add(1)
+	select {
+	case a.events <- ev:
+		return nil
+	default:
+		a.pending.Done()
+		return fmt.Errorf("%s: %d notifications waiting, dropped this one", name(a.n), asyncBacklog)
+	}
+}
+
+// Flush waits for queued events to be handed over, then flushes the
+// wrapped notifier.
+func (a *Async) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("%d notification(s) still waiting: %w", len(a.events), ctx.Err())
+	}
+	return Flush(ctx, a.n)
+}
+
+func (a *Async) run() {
+	for ev := range a.events {
+		if err := a.n.Notify(ev); err != nil && a.onErr != nil {
+			a.onErr(ev, err)
+		}
+		a.pending.Done()
+	}
+}
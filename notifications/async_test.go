@@ -0,0 +1,74 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowNotifier records events after holding each one for delay.
+type slowNotifier struct {
+	delay time.Duration
+	mu    sync.Mutex
+	got   []EventType
+}
+
+func (s *slowNotifier) Notify(ev Event) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, ev.Type)
+	if ev.Type == EventError {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestAsyncDoesNotBlock(t *testing.T) {
+	slow := &slowNotifier{delay: 100 * time.Millisecond}
+	var failed []EventType
+	a := NewAsync(slow, func(ev Event, err error) { failed = append(failed, ev.Type) })
+
+	start := time.Now()
+	for _, typ := range []EventType{EventStarted, EventError, EventStopped} {
+		if err := a.Notify(Event{Type: typ}); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("Notify blocked for %s", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if want := []EventType{EventStarted, EventError, EventStopped}; len(slow.got) != 3 || slow.got[0] != want[0] || slow.got[2] != want[2] {
+		t.Errorf("delivered %v, want %v in order", slow.got, want)
+	}
+	if len(failed) != 1 || failed[0] != EventError {
+		t.Errorf("onErr saw %v, want the error event", failed)
+	}
+}
+
+func TestDiscordInlineRetryIsBounded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	err := NewDiscordNotifier(srv.URL).SendWebhook(DiscordWebhook{Content: "hi"})
+	if err == nil {
+		t.Fatal("SendWebhook succeeded against a rate-limited server")
+	}
+	if d := time.Since(start); d > maxInlineWait {
+		t.Errorf("SendWebhook took %s, want at most %s", d, maxInlineWait)
+	}
+}
@@ -2,8 +2,11 @@ package notifications
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -40,34 +43,142 @@ type DiscordEmbedFooter struct {
 // DiscordNotifier handles Discord webhook notifications
 type DiscordNotifier struct {
 	webhookURL string
+	client     *http.Client
+	limit      rateLimiter
+	queue      *DeliveryQueue // nil = deliver inline
 }
 
 // NewDiscordNotifier creates a new Discord notifier with the given webhook URL
 func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
 	return &DiscordNotifier{
 		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
-// SendWebhook sends a webhook to Discord
+// EnableQueue switches the notifier to background delivery: SendWebhook
+// enqueues and returns at once, and messages still undelivered are kept
+// in path (if non-empty) and retried on the next start.
+func (d *DiscordNotifier) EnableQueue(path string) (*DeliveryQueue, error) {
+	q, err := newDeliveryQueue(d, path)
+	if err != nil {
+		return nil, err
+	}
+	d.queue = q
+	return q, nil
+}
+
+// Queue returns the delivery queue, or nil when sending inline.
+func (d *DiscordNotifier) Queue() *DeliveryQueue { return d.queue }
+
+// Flush waits for queued messages to go out; a no-op without a queue.
+func (d *DiscordNotifier) Flush(ctx context.Context) error {
+	if d.queue == nil {
+		return nil
+	}
+	return d.queue.Flush(ctx)
+}
+
+// String names the backend in aggregated errors.
+func (d *DiscordNotifier) String() string { return "discord" }
+
+// Notify implements Notifier by rendering ev as the matching embed.
+func (d *DiscordNotifier) Notify(ev Event) error {
+	switch ev.Type {
+	case EventStarted:
+		return d.SendMonitoringStarted(ev.Restaurant, ev.Date, ev.Time, ev.PartySize)
+	case EventSlotFound:
+		return d.sendSlotFound(ev.Restaurant, ev.Date, ev.Time, ev.PartySize, ev.URL, ev.Reason)
+	case EventBooked:
+		return d.SendBooked(ev.Restaurant, ev.Booking, ev.URL)
+	case EventUpgrade:
+		return d.SendUpgrade(ev.Restaurant, ev.Date, ev.Current, ev.Time, ev.PartySize, ev.URL, ev.Booking)
+	case EventAlternatives:
+		return d.SendAlternativeTimes(ev.Restaurant, ev.Date, ev.PartySize, ev.Alternatives, ev.URL)
+	case EventStopped:
+		return d.SendMonitoringStopped(ev.Restaurant, ev.Reason)
+	case EventDegraded:
+		return d.SendDegraded(ev.Restaurant, ev.Reason)
+	case EventRecovered:
+		return d.SendRecovered(ev.Restaurant, ev.Reason)
+	case EventError:
+		return d.SendError(ev.Restaurant, ev.Reason)
+	default:
+		return fmt.Errorf("unknown event type %q", ev.Type)
+	}
+}
+
+// SendWebhook sends a webhook to Discord. With a queue it only enqueues;
+// otherwise it retries rate limits and transient failures for at most
+// maxInlineWait before giving up, leaving long waits to the queue.
 func (d *DiscordNotifier) SendWebhook(webhook DiscordWebhook) error {
 	if d.webhookURL == "" {
 		return fmt.Errorf("discord webhook URL not configured")
 	}
+	if d.queue != nil {
+		_, err := d.queue.Enqueue(webhook)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxInlineWait)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	for attempt := 1; ; attempt++ {
+		err := d.attempt(ctx, webhook)
+		var se *sendError
+		if err == nil || !errors.As(err, &se) || !se.retryable() || attempt == maxInlineAttempts {
+			return err
+		}
+		wait := nextWait(se, attempt)
+		if time.Until(deadline) < wait {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
 
+// attempt makes one POST, waiting out the rate-limit bucket first and
+// recording what the response says about it.
+func (d *DiscordNotifier) attempt(ctx context.Context, webhook DiscordWebhook) error {
 	jsonData, err := json.Marshal(webhook)
 	if err != nil {
 		return fmt.Errorf("failed to marshal webhook data: %v", err)
 	}
+	if err := d.limit.wait(ctx); err != nil {
+		return err
+	}
 
-	resp, err := http.Post(d.webhookURL, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhookURL, bytes.NewReader(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %v", err)
+		return fmt.Errorf("failed to build webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return &sendError{msg: fmt.Sprintf("failed to send webhook: %v", err)}
 	}
 	defer resp.Body.Close()
+	d.limit.observe(resp.Header)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook request failed with status: %d", resp.StatusCode)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		se := &sendError{
+			status: resp.StatusCode,
+			msg:    fmt.Sprintf("webhook request failed with status: %d", resp.StatusCode),
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			se.retryAfter = retryAfter(resp.Header, body)
+			d.limit.pause(se.retryAfter)
+			se.msg += fmt.Sprintf(" (retry after %s)", se.retryAfter.Round(time.Millisecond))
+		}
+		return se
 	}
 
 	return nil
@@ -75,6 +186,12 @@ func (d *DiscordNotifier) SendWebhook(webhook DiscordWebhook) error {
 
 // SendSlotFound sends a notification when the exact preferred slot is found
 func (d *DiscordNotifier) SendSlotFound(restaurant monitor.AutoResult, date, timeSlot string, partySize int, reservationURL string) error {
+	return d.sendSlotFound(restaurant, date, timeSlot, partySize, reservationURL, "")
+}
+
+// sendSlotFound is SendSlotFound, also telling why auto-book failed when
+// bookErr is set.
+func (d *DiscordNotifier) sendSlotFound(restaurant monitor.AutoResult, date, timeSlot string, partySize int, reservationURL, bookErr string) error {
 	webhook := DiscordWebhook{
 		Content: "🎉 **Reservation Available!**",
 		Embeds: []DiscordEmbed{
@@ -127,6 +244,135 @@ func (d *DiscordNotifier) SendSlotFound(restaurant monitor.AutoResult, date, tim
 			},
 		},
 	}
+	if bookErr != "" {
+		embed := &webhook.Embeds[0]
+		embed.Fields = append(embed.Fields, DiscordEmbedField{
+			Name:  "⚠️ Auto-book failed",
+			Value: bookErr + "\nBook it yourself with the link above.",
+		})
+	}
+
+	return d.SendWebhook(webhook)
+}
+
+// SendBooked sends a notification when auto-book reserved a slot
+func (d *DiscordNotifier) SendBooked(restaurant monitor.AutoResult, b *monitor.Booking, reservationURL string) error {
+	title, confirmation, color := "📗 Reservation Booked!", b.ConfirmationNumber, 0x2ECC71
+	if b.DryRun {
+		title, confirmation, color = "🧪 Would Book (dry run)", "—", 0x95A5A6
+	}
+	webhook := DiscordWebhook{
+		Content: "📗 **Reservation Booked!**",
+		Embeds: []DiscordEmbed{
+			{
+				Title:       title,
+				Description: fmt.Sprintf("A table at **%s** was reserved for you.", restaurant.Name),
+				Color:       color,
+				URL:         reservationURL,
+				Fields: []DiscordEmbedField{
+					{
+						Name:   "🏪 Restaurant",
+						Value:  restaurant.Name,
+						Inline: true,
+					},
+					{
+						Name:   "📅 Date",
+						Value:  b.Date,
+						Inline: true,
+					},
+					{
+						Name:   "⏰ Time",
+						Value:  b.Time,
+						Inline: true,
+					},
+					{
+						Name:   "👥 Party Size",
+						Value:  fmt.Sprintf("%d", b.PartySize),
+						Inline: true,
+					},
+					{
+						Name:   "🎫 Confirmation",
+						Value:  confirmation,
+						Inline: true,
+					},
+				},
+				Footer: &DiscordEmbedFooter{
+					Text: "OpenTable Monitor • Check your email for OpenTable's confirmation",
+				},
+				Timestamp: time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+	if b.DryRun {
+		webhook.Content = "🧪 **Dry run — nothing was booked**"
+		webhook.Embeds[0].Description = fmt.Sprintf("Auto-book would have reserved this table at **%s**.", restaurant.Name)
+	}
+
+	return d.SendWebhook(webhook)
+}
+
+// SendUpgrade sends a notification when a slot better than the booking
+// held opens up, or when the booking was moved to it (moved != nil)
+func (d *DiscordNotifier) SendUpgrade(restaurant monitor.AutoResult, date, current, candidate string, partySize int, reservationURL string, moved *monitor.Booking) error {
+	content, title := "⬆️ **Better Time Available!**", "⬆️ Upgrade Your Booking"
+	description := fmt.Sprintf("A slot closer to your ideal time opened at **%s**.", restaurant.Name)
+	action := fmt.Sprintf("[Change your reservation](%s)", reservationURL)
+	switch {
+	case moved != nil && moved.DryRun:
+		content = "🧪 **Dry run — booking not moved**"
+		action = "Auto-modify would have moved your booking here."
+	case moved != nil:
+		content, title = "✏️ **Reservation Moved!**", "✏️ Booking Upgraded"
+		description = fmt.Sprintf("Your booking at **%s** was moved to the better time.", restaurant.Name)
+		action = "Confirmation #" + moved.ConfirmationNumber
+	}
+	webhook := DiscordWebhook{
+		Content: content,
+		Embeds: []DiscordEmbed{
+			{
+				Title:       title,
+				Description: description,
+				Color:       0x3498DB,
+				URL:         reservationURL,
+				Fields: []DiscordEmbedField{
+					{
+						Name:   "🏪 Restaurant",
+						Value:  restaurant.Name,
+						Inline: true,
+					},
+					{
+						Name:   "📅 Date",
+						Value:  date,
+						Inline: true,
+					},
+					{
+						Name:   "👥 Party Size",
+						Value:  fmt.Sprintf("%d", partySize),
+						Inline: true,
+					},
+					{
+						Name:   "📌 Current",
+						Value:  current,
+						Inline: true,
+					},
+					{
+						Name:   "✨ Candidate",
+						Value:  candidate,
+						Inline: true,
+					},
+					{
+						Name:   "➡️ Next Step",
+						Value:  action,
+						Inline: false,
+					},
+				},
+				Footer: &DiscordEmbedFooter{
+					Text: "OpenTable Monitor • Upgrade mode",
+				},
+				Timestamp: time.Now().Format(time.RFC3339),
+			},
+		},
+	}
 
 	return d.SendWebhook(webhook)
 }
@@ -313,3 +559,77 @@ func (d *DiscordNotifier) SendError(restaurant monitor.AutoResult, errorMsg stri
 
 	return d.SendWebhook(webhook)
 }
+
+// SendDegraded warns that a watch keeps failing to poll
+func (d *DiscordNotifier) SendDegraded(restaurant monitor.AutoResult, detail string) error {
+	webhook := DiscordWebhook{
+		Content: "🩺 **Monitor Degraded**",
+		Embeds: []DiscordEmbed{
+			{
+				Title:       "⚠️ OpenTable Monitor Degraded",
+				Description: fmt.Sprintf("Checks for **%s** keep failing. The monitor is still retrying.", restaurant.Name),
+				Color:       0xFFAA00, // Orange color
+				Fields: []DiscordEmbedField{
+					{
+						Name:   "🏪 Restaurant",
+						Value:  restaurant.Name,
+						Inline: true,
+					},
+					{
+						Name:   "📍 Location",
+						Value:  fmt.Sprintf("%s, %s", restaurant.Neighborhood, restaurant.Metro),
+						Inline: true,
+					},
+					{
+						Name:   "🩺 Details",
+						Value:  detail,
+						Inline: false,
+					},
+				},
+				Footer: &DiscordEmbedFooter{
+					Text: "OpenTable Monitor • You'll hear again once it recovers",
+				},
+				Timestamp: time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	return d.SendWebhook(webhook)
+}
+
+// SendRecovered reports that a degraded watch is polling normally again
+func (d *DiscordNotifier) SendRecovered(restaurant monitor.AutoResult, detail string) error {
+	webhook := DiscordWebhook{
+		Content: "✅ **Monitor Recovered**",
+		Embeds: []DiscordEmbed{
+			{
+				Title:       "💚 OpenTable Monitor Recovered",
+				Description: fmt.Sprintf("Checks for **%s** are working again.", restaurant.Name),
+				Color:       0x00FF00, // Green color
+				Fields: []DiscordEmbedField{
+					{
+						Name:   "🏪 Restaurant",
+						Value:  restaurant.Name,
+						Inline: true,
+					},
+					{
+						Name:   "📍 Location",
+						Value:  fmt.Sprintf("%s, %s", restaurant.Neighborhood, restaurant.Metro),
+						Inline: true,
+					},
+					{
+						Name:   "🩺 Details",
+						Value:  detail,
+						Inline: false,
+					},
+				},
+				Footer: &DiscordEmbedFooter{
+					Text: "OpenTable Monitor",
+				},
+				Timestamp: time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	return d.SendWebhook(webhook)
+}
@@ -0,0 +1,524 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	ttemplate "text/template"
+	"time"
+
+	"opentable-monitor/monitor"
+)
+
+// smtpTimeout bounds a whole SMTP conversation, so a server that stalls
+// can't hold up the notifiers waiting on it.
+var smtpTimeout = time.Minute
+
+// Email transport security modes
+const (
+	SecurityStartTLS = "starttls" // plain connect, then upgrade (port 587)
+	SecurityTLS      = "tls"      // implicit TLS from the first byte (port 465)
+	SecurityNone     = "none"     // plain text; only for local relays / tests
+)
+
+// EmailConfig describes the SMTP server and envelope.
+type EmailConfig struct {
+	Host     string
+	Port     int // 0 = 587 for starttls, 465 for tls, 25 for none
+	Username string
+	Password string
+	From     string
+	To       []string
+	Security string // SecurityStartTLS (default), SecurityTLS or SecurityNone
+
+	// TLSConfig overrides how the server's certificate is checked, e.g.
+	// to trust a private CA. nil verifies Host against the system roots.
+	TLSConfig *tls.Config
+}
+
+// EmailNotifier sends multipart HTML/plain-text emails over SMTP.
+type EmailNotifier struct {
+	cfg EmailConfig
+}
+
+// NewEmailNotifier creates a new email notifier for the given SMTP setup
+func NewEmailNotifier(cfg EmailConfig) *EmailNotifier {
+	if cfg.Security == "" {
+		cfg.Security = SecurityStartTLS
+	}
+	if cfg.Port == 0 {
+		switch cfg.Security {
+		case SecurityTLS:
+			cfg.Port = 465
+		case SecurityNone:
+			cfg.Port = 25
+		default:
+			cfg.Port = 587
+		}
+	}
+	return &EmailNotifier{cfg: cfg}
+}
+
+// String names the backend in aggregated errors.
+func (e *EmailNotifier) String() string { return "email" }
+
+// Notify implements Notifier by rendering ev as the matching email.
+func (e *EmailNotifier) Notify(ev Event) error {
+	switch ev.Type {
+	case EventStarted:
+		return e.SendMonitoringStarted(ev.Restaurant, ev.Date, ev.Time, ev.PartySize)
+	case EventSlotFound:
+		return e.sendSlotFound(ev.Restaurant, ev.Date, ev.Time, ev.PartySize, ev.URL, ev.Reason)
+	case EventBooked:
+		return e.SendBooked(ev.Restaurant, ev.Booking, ev.URL)
+	case EventUpgrade:
+		return e.SendUpgrade(ev.Restaurant, ev.Date, ev.Current, ev.Time, ev.PartySize, ev.URL, ev.Booking)
+	case EventAlternatives:
+		return e.SendAlternativeTimes(ev.Restaurant, ev.Date, ev.PartySize, ev.Alternatives, ev.URL)
+	case EventStopped:
+		return e.SendMonitoringStopped(ev.Restaurant, ev.Reason)
+	case EventDegraded:
+		return e.SendDegraded(ev.Restaurant, ev.Reason)
+	case EventRecovered:
+		return e.SendRecovered(ev.Restaurant, ev.Reason)
+	case EventError:
+		return e.SendError(ev.Restaurant, ev.Reason)
+	default:
+		return fmt.Errorf("unknown event type %q", ev.Type)
+	}
+}
+
+// emailField is one label/value row of the details table.
+type emailField struct {
+	Label string
+	Value string
+}
+
+// emailLine is one alternative time, with its booking link split out.
+type emailLine struct {
+	Text string
+	URL  string
+}
+
+// emailContent is everything the templates need for one message.
+type emailContent struct {
+	Subject     string
+	Title       string
+	Intro       string
+	Fields      []emailField
+	Lines       []emailLine
+	URL         string // the prominent "Book Now" link
+	ButtonLabel string
+	Footer      string
+	Color       string // accent colour, CSS hex
+}
+
+// SendSlotFound sends a notification when the exact preferred slot is found
+func (e *EmailNotifier) SendSlotFound(restaurant monitor.AutoResult, date, timeSlot string, partySize int, reservationURL string) error {
+	return e.sendSlotFound(restaurant, date, timeSlot, partySize, reservationURL, "")
+}
+
+// sendSlotFound is SendSlotFound, also telling why auto-book failed when
+// bookErr is set.
+func (e *EmailNotifier) sendSlotFound(restaurant monitor.AutoResult, date, timeSlot string, partySize int, reservationURL, bookErr string) error {
+	c := emailContent{
+		Subject: fmt.Sprintf("🎉 %s — %s at %s is available", restaurant.Name, date, timeSlot),
+		Title:   "✅ Exact Time Slot Found!",
+		Intro:   fmt.Sprintf("Your preferred reservation slot is now available at %s!", restaurant.Name),
+		Fields: append(restaurantFields(restaurant),
+			emailField{"📅 Date", date},
+			emailField{"⏰ Time", timeSlot},
+			emailField{"👥 Party Size", strconv.Itoa(partySize)},
+		),
+		URL:         reservationURL,
+		ButtonLabel: "Book Now",
+		Footer:      "Book quickly before it's taken!",
+		Color:       "#00AA00",
+	}
+	if bookErr != "" {
+		c.Intro += " Auto-book couldn't reserve it, so book it yourself."
+		c.Fields = append(c.Fields, emailField{"⚠️ Auto-book failed", bookErr})
+	}
+	return e.send(c)
+}
+
+// SendBooked sends a notification when auto-book reserved a slot
+func (e *EmailNotifier) SendBooked(restaurant monitor.AutoResult, b *monitor.Booking, reservationURL string) error {
+	c := emailContent{
+		Subject: fmt.Sprintf("📗 Booked %s — %s at %s (confirmation %s)", restaurant.Name, b.Date, b.Time, b.ConfirmationNumber),
+		Title:   "📗 Reservation Booked!",
+		Intro:   fmt.Sprintf("A table at %s was reserved for you.", restaurant.Name),
+		Fields: append(restaurantFields(restaurant),
+			emailField{"📅 Date", b.Date},
+			emailField{"⏰ Time", b.Time},
+			emailField{"👥 Party Size", strconv.Itoa(b.PartySize)},
+			emailField{"🎫 Confirmation", b.ConfirmationNumber},
+		),
+		URL:         reservationURL,
+		ButtonLabel: "View on OpenTable",
+		Footer:      "Check your email for OpenTable's confirmation.",
+		Color:       "#2ECC71",
+	}
+	if b.DryRun {
+		c.Subject = fmt.Sprintf("🧪 Dry run: would book %s — %s at %s", restaurant.Name, b.Date, b.Time)
+		c.Title = "🧪 Would Book (dry run)"
+		c.Intro = fmt.Sprintf("Auto-book would have reserved this table at %s. Nothing was booked.", restaurant.Name)
+		c.Fields[len(c.Fields)-1].Value = "—"
+	}
+	return e.send(c)
+}
+
+// SendUpgrade sends a notification when a slot better than the booking
+// held opens up, or when the booking was moved to it (moved != nil)
+func (e *EmailNotifier) SendUpgrade(restaurant monitor.AutoResult, date, current, candidate string, partySize int, reservationURL string, moved *monitor.Booking) error {
+	c := emailContent{
+		Subject: fmt.Sprintf("⬆️ %s — %s at %s instead of %s", restaurant.Name, date, candidate, current),
+		Title:   "⬆️ Upgrade Your Booking",
+		Intro:   fmt.Sprintf("A slot closer to your ideal time opened at %s.", restaurant.Name),
+		Fields: append(restaurantFields(restaurant),
+			emailField{"📅 Date", date},
+			emailField{"👥 Party Size", strconv.Itoa(partySize)},
+			emailField{"📌 Current", current},
+			emailField{"✨ Candidate", candidate},
+		),
+		URL:         reservationURL,
+		ButtonLabel: "Change Reservation",
+		Footer:      "Upgrade mode",
+		Color:       "#3498DB",
+	}
+	switch {
+	case moved != nil && moved.DryRun:
+		c.Intro += " Auto-modify would have moved your booking here (dry run)."
+	case moved != nil:
+		c.Subject = fmt.Sprintf("✏️ %s — moved to %s at %s (confirmation %s)", restaurant.Name, date, candidate, moved.ConfirmationNumber)
+		c.Title = "✏️ Booking Upgraded"
+		c.Intro = fmt.Sprintf("Your booking at %s was moved to the better time.", restaurant.Name)
+		c.Fields = append(c.Fields, emailField{"🎫 Confirmation", moved.ConfirmationNumber})
+		c.ButtonLabel = "View on OpenTable"
+	}
+	return e.send(c)
+}
+
+// SendAlternativeTimes sends a notification when alternative times are available
+func (e *EmailNotifier) SendAlternativeTimes(restaurant monitor.AutoResult, date string, partySize int, alternativeTimes []string, reservationURL string) error {
+	lines := make([]emailLine, 0, len(alternativeTimes))
+	for _, t := range alternativeTimes {
+		lines = append(lines, splitLink(t))
+	}
+	return e.send(emailContent{
+		Subject: fmt.Sprintf("⏰ %d alternative time(s) at %s on %s", len(alternativeTimes), restaurant.Name, date),
+		Title:   "🔄 Alternative Reservation Times",
+		Intro:   fmt.Sprintf("Your exact preferred time isn't available, but there are other options at %s!", restaurant.Name),
+		Fields: append(restaurantFields(restaurant),
+			emailField{"📅 Date", date},
+			emailField{"👥 Party Size", strconv.Itoa(partySize)},
+		),
+		Lines:       lines,
+		URL:         reservationURL,
+		ButtonLabel: "Book First Slot",
+		Footer:      "Consider booking one of these times!",
+		Color:       "#FFAA00",
+	})
+}
+
+// SendMonitoringStarted sends a notification when monitoring begins
+func (e *EmailNotifier) SendMonitoringStarted(restaurant monitor.AutoResult, date, preferredTime string, partySize int) error {
+	return e.send(emailContent{
+		Subject: fmt.Sprintf("🔍 Monitoring started: %s", restaurant.Name),
+		Title:   "🎯 OpenTable Reservation Monitor Active",
+		Intro:   fmt.Sprintf("Now monitoring %s for available reservations!", restaurant.Name),
+		Fields: append(restaurantFields(restaurant),
+			emailField{"📅 Date", date},
+			emailField{"⏰ Preferred Time", preferredTime},
+			emailField{"👥 Party Size", strconv.Itoa(partySize)},
+		),
+		Footer: "You'll be notified when slots become available!",
+		Color:  "#5865F2",
+	})
+}
+
+// SendMonitoringStopped sends a notification when monitoring stops
+func (e *EmailNotifier) SendMonitoringStopped(restaurant monitor.AutoResult, reason string) error {
+	return e.send(emailContent{
+		Subject: fmt.Sprintf("⏹️ Monitoring stopped: %s", restaurant.Name),
+		Title:   "🛑 OpenTable Monitor Stopped",
+		Intro:   fmt.Sprintf("Monitoring for %s has been stopped.", restaurant.Name),
+		Fields:  append(restaurantFields(restaurant), emailField{"❓ Reason", reason}),
+		Color:   "#FF0000",
+	})
+}
+
+// SendError sends an error notification
+func (e *EmailNotifier) SendError(restaurant monitor.AutoResult, errorMsg string) error {
+	return e.send(emailContent{
+		Subject: fmt.Sprintf("❌ Monitor error: %s", restaurant.Name),
+		Title:   "⚠️ OpenTable Monitor Error",
+		Intro:   fmt.Sprintf("An error occurred while monitoring %s.", restaurant.Name),
+		Fields:  append(restaurantFields(restaurant), emailField{"❌ Error", errorMsg}),
+		Footer:  "Please check the application",
+		Color:   "#FF0000",
+	})
+}
+
+// SendDegraded warns that a watch keeps failing to poll
+func (e *EmailNotifier) SendDegraded(restaurant monitor.AutoResult, detail string) error {
+	return e.send(emailContent{
+		Subject: fmt.Sprintf("🩺 Monitor degraded: %s", restaurant.Name),
+		Title:   "⚠️ OpenTable Monitor Degraded",
+		Intro:   fmt.Sprintf("Checks for %s keep failing. The monitor is still retrying.", restaurant.Name),
+		Fields:  append(restaurantFields(restaurant), emailField{"🩺 Details", detail}),
+		Footer:  "You'll hear again once it recovers",
+		Color:   "#FFAA00",
+	})
+}
+
+// SendRecovered reports that a degraded watch is polling normally again
+func (e *EmailNotifier) SendRecovered(restaurant monitor.AutoResult, detail string) error {
+	return e.send(emailContent{
+		Subject: fmt.Sprintf("✅ Monitor recovered: %s", restaurant.Name),
+		Title:   "💚 OpenTable Monitor Recovered",
+		Intro:   fmt.Sprintf("Checks for %s are working again.", restaurant.Name),
+		Fields:  append(restaurantFields(restaurant), emailField{"🩺 Details", detail}),
+		Color:   "#00AA00",
+	})
+}
+
+func restaurantFields(r monitor.AutoResult) []emailField {
+	return []emailField{
+		{"🏪 Restaurant", r.Name},
+		{"📍 Location", fmt.Sprintf("%s, %s", r.Neighborhood, r.Metro)},
+	}
+}
+
+// splitLink separates "• 19:00 [bar] → [Book](url)" into text and URL.
+func splitLink(line string) emailLine {
+	m := markdownLink.FindStringSubmatch(line)
+	if m == nil {
+		return emailLine{Text: strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "•"))}
+	}
+	text := strings.TrimSpace(strings.Replace(line, m[0], "", 1))
+	text = strings.TrimSpace(strings.TrimSuffix(text, "→"))
+	text = strings.TrimSpace(strings.TrimPrefix(text, "•"))
+	return emailLine{Text: text, URL: m[2]}
+}
+
+var emailText = ttemplate.Must(ttemplate.New("text").Parse(`{{.Title}}
+
+{{.Intro}}
+{{range .Fields}}
+{{.Label}}: {{.Value}}{{end}}
+{{if .Lines}}
+Available times:
+{{range .Lines}}  • {{.Text}}{{if .URL}}
+    {{.URL}}{{end}}
+{{end}}{{end}}{{if .URL}}
+{{.ButtonLabel}}:
+{{.URL}}
+{{end}}
+--
+OpenTable Monitor{{if .Footer}} • {{.Footer}}{{end}}
+`))
+
+var emailHTML = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html><body style="margin:0;padding:24px;background:#f4f4f5;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#18181b">
+<table role="presentation" width="100%" style="max-width:560px;margin:0 auto;background:#ffffff;border-radius:8px;border-top:6px solid {{.Color}}">
+<tr><td style="padding:24px">
+<h1 style="margin:0 0 8px;font-size:20px">{{.Title}}</h1>
+<p style="margin:0 0 16px">{{.Intro}}</p>
+{{if .URL}}<p style="margin:0 0 24px;text-align:center">
+<a href="{{.URL}}" style="display:inline-block;padding:14px 28px;background:{{.Color}};color:#ffffff;font-size:18px;font-weight:bold;text-decoration:none;border-radius:6px">{{.ButtonLabel}}</a>
+</p>{{end}}
+<table role="presentation" style="width:100%;border-collapse:collapse;margin-bottom:16px">
+{{range .Fields}}<tr><td style="padding:4px 12px 4px 0;color:#71717a;white-space:nowrap">{{.Label}}</td><td style="padding:4px 0">{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Lines}}<h2 style="font-size:16px;margin:0 0 8px">⏰ Available Times</h2>
+<ul style="margin:0 0 16px;padding-left:20px">
+{{range .Lines}}<li>{{.Text}}{{if .URL}} — <a href="{{.URL}}">Book</a>{{end}}</li>
+{{end}}</ul>{{end}}
+{{if .URL}}<p style="font-size:12px;color:#71717a;word-break:break-all">Button not working? Open {{.URL}}</p>{{end}}
+<p style="font-size:12px;color:#a1a1aa;margin:16px 0 0">OpenTable Monitor{{if .Footer}} • {{.Footer}}{{end}}</p>
+</td></tr></table>
+</body></html>
+`))
+
+// send renders content as a multipart/alternative message and delivers it.
+func (e *EmailNotifier) send(content emailContent) error {
+	if e.cfg.Host == "" || e.cfg.From == "" || len(e.cfg.To) == 0 {
+		return fmt.Errorf("email notifier not configured (host, from and to are required)")
+	}
+
+	msg, err := e.buildMessage(content)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %v", err)
+	}
+	if err := e.deliver(msg); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// buildMessage assembles headers plus plain-text and HTML parts.
+func (e *EmailNotifier) buildMessage(content emailContent) ([]byte, error) {
+	var textBody, htmlBody bytes.Buffer
+	if err := emailText.Execute(&textBody, content); err != nil {
+		return nil, err
+	}
+	if err := emailHTML.Execute(&htmlBody, content); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		ctype string
+		data  []byte
+	}{
+		{"text/plain; charset=UTF-8", textBody.Bytes()},
+		{"text/html; charset=UTF-8", htmlBody.Bytes()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ctype},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.data); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := []struct{ k, v string }{
+		{"From", e.cfg.From},
+		{"To", strings.Join(e.cfg.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", content.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(e.cfg.From)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h.k, h.v)
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// deliver opens the SMTP session according to cfg.Security and sends msg.
+func (e *EmailNotifier) deliver(msg []byte) error {
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	tlsCfg := &tls.Config{}
+	if e.cfg.TLSConfig != nil {
+		tlsCfg = e.cfg.TLSConfig.Clone()
+	}
+	if tlsCfg.ServerName == "" {
+		tlsCfg.ServerName = e.cfg.Host
+	}
+
+	var c *smtp.Client
+	switch e.cfg.Security {
+	case SecurityTLS:
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsCfg)
+		if err != nil {
+			return err
+		}
+		conn.SetDeadline(time.Now().Add(smtpTimeout))
+		if c, err = smtp.NewClient(conn, e.cfg.Host); err != nil {
+			conn.Close()
+			return err
+		}
+	case SecurityStartTLS, SecurityNone:
+		conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+		if err != nil {
+			return err
+		}
+		conn.SetDeadline(time.Now().Add(smtpTimeout)) // carries over to the STARTTLS connection
+		if c, err = smtp.NewClient(conn, e.cfg.Host); err != nil {
+			conn.Close()
+			return err
+		}
+		if e.cfg.Security == SecurityStartTLS {
+			if err := c.StartTLS(tlsCfg); err != nil {
+				c.Close()
+				return fmt.Errorf("starttls: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown security mode %q", e.cfg.Security)
+	}
+	defer c.Close()
+
+	if e.cfg.Username != "" {
+		// Sending unauthenticated would only get the mail dropped or
+		// bounced later, so a server that can't log us in is an error.
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("auth: %s does not offer AUTH", addr)
+		}
+		auth := smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(addressOf(e.cfg.From)); err != nil {
+		return err
+	}
+	for _, to := range e.cfg.To {
+		if err := c.Rcpt(addressOf(to)); err != nil {
+			return fmt.Errorf("rcpt %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// addressOf extracts the bare address from "Name <addr>".
+func addressOf(s string) string {
+	if i := strings.LastIndex(s, "<"); i >= 0 {
+		if j := strings.LastIndex(s, ">"); j > i {
+			return s[i+1 : j]
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// messageID makes a unique Message-ID on the sender's domain.
+func messageID(from string) string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	domain := "opentable-monitor.local"
+	if at := strings.LastIndex(addressOf(from), "@"); at >= 0 {
+		domain = addressOf(from)[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b[:]), domain)
+}
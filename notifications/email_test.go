@@ -0,0 +1,243 @@
+package notifications
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"opentable-monitor/monitor"
+)
+
+// smtpServer is an in-process SMTP server that accepts one session and
+// records what the client sent.
+type smtpServer struct {
+	ln       net.Listener
+	auth     bool        // advertise AUTH PLAIN
+	tls      *tls.Config // advertise STARTTLS with this config
+	done     chan struct{}
+	commands []string
+	login    string // decoded AUTH PLAIN payload
+	rcpts    []string
+	data     string
+}
+
+func startSMTP(t *testing.T, auth bool, cfg *tls.Config) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, auth: auth, tls: cfg, done: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve(t)
+	return s
+}
+
+func (s *smtpServer) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *smtpServer) serve(t *testing.T) {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		s.commands = append(s.commands, verb)
+		switch verb {
+		case "EHLO":
+			ext := []string{"localhost"}
+			if s.tls != nil {
+				ext = append(ext, "STARTTLS")
+			}
+			if s.auth {
+				ext = append(ext, "AUTH PLAIN")
+			}
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, e)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 go ahead")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				t.Errorf("server handshake: %v", err)
+				return
+			}
+			conn = tc
+			tp = textproto.NewConn(tc)
+		case "AUTH":
+			raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			s.login = string(raw)
+			tp.PrintfLine("235 ok")
+		case "MAIL":
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.rcpts = append(s.rcpts, arg)
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			raw, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(raw)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown")
+		}
+	}
+}
+
+// testCerts returns a server config with httptest's certificate, valid
+// for 127.0.0.1, and a client config that trusts it.
+func testCerts(t *testing.T) (server, client *tls.Config) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	server = &tls.Config{Certificates: srv.TLS.Certificates}
+	client = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	return server, client
+}
+
+func TestEmailStartTLSWithAuth(t *testing.T) {
+	serverTLS, clientTLS := testCerts(t)
+	s := startSMTP(t, true, serverTLS)
+
+	n := NewEmailNotifier(EmailConfig{
+		Host:      "127.0.0.1",
+		Port:      s.port(),
+		Username:  "me",
+		Password:  "secret",
+		From:      "Monitor <monitor@example.com>",
+		To:        []string{"a@example.com", "B <b@example.com>"},
+		TLSConfig: clientTLS,
+	})
+	err := n.SendSlotFound(monitor.AutoResult{Name: "Nopa"}, "2026-11-02", "19:30", 2, "https://www.opentable.com/r/nopa")
+	if err != nil {
+		t.Fatalf("SendSlotFound: %v", err)
+	}
+	<-s.done
+
+	if got := strings.Join(s.commands, " "); !strings.HasPrefix(got, "EHLO STARTTLS EHLO AUTH MAIL") {
+		t.Errorf("commands = %s, want STARTTLS before AUTH", got)
+	}
+	if s.login != "\x00me\x00secret" {
+		t.Errorf("AUTH PLAIN = %q", s.login)
+	}
+	if want := "TO:<a@example.com> TO:<b@example.com>"; strings.Join(s.rcpts, " ") != want {
+		t.Errorf("recipients = %v, want %s", s.rcpts, want)
+	}
+	for _, want := range []string{"Subject: =?utf-8?q?", "multipart/alternative", "text/plain", "text/html", "Nopa"} {
+		if !strings.Contains(s.data, want) {
+			t.Errorf("message lacks %q", want)
+		}
+	}
+}
+
+func TestEmailRefusesWithoutAuth(t *testing.T) {
+	s := startSMTP(t, false, nil)
+
+	n := NewEmailNotifier(EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     s.port(),
+		Username: "me",
+		Password: "secret",
+		From:     "monitor@example.com",
+		To:       []string{"a@example.com"},
+		Security: SecurityNone,
+	})
+	err := n.SendError(monitor.AutoResult{Name: "Nopa"}, "boom")
+	if err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Fatalf("err = %v, want a missing-AUTH error", err)
+	}
+	s.ln.Close()
+	<-s.done
+	for _, c := range s.commands {
+		if c == "MAIL" || c == "DATA" {
+			t.Errorf("sent %s to a server that can't authenticate us", c)
+		}
+	}
+}
+
+func TestEmailNoCredentials(t *testing.T) {
+	s := startSMTP(t, false, nil)
+
+	n := NewEmailNotifier(EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     s.port(),
+		From:     "monitor@example.com",
+		To:       []string{"a@example.com"},
+		Security: SecurityNone,
+	})
+	if err := n.SendError(monitor.AutoResult{Name: "Nopa"}, "boom"); err != nil {
+		t.Fatalf("SendError: %v", err)
+	}
+	<-s.done
+	if s.data == "" {
+		t.Error("no message delivered")
+	}
+}
+
+func TestEmailDefaultPorts(t *testing.T) {
+	for security, want := range map[string]int{"": 587, SecurityStartTLS: 587, SecurityTLS: 465, SecurityNone: 25} {
+		if got := NewEmailNotifier(EmailConfig{Security: security}).cfg.Port; got != want {
+			t.Errorf("security %q: port %d, want %d", security, got, want)
+		}
+	}
+}
+
+func TestEmailStalledServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			conn.Read(make([]byte, 1)) // accept, then never greet
+		}
+	}()
+	defer func(d time.Duration) { smtpTimeout = d }(smtpTimeout)
+	smtpTimeout = 200 * time.Millisecond
+
+	n := NewEmailNotifier(EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     ln.Addr().(*net.TCPAddr).Port,
+		From:     "monitor@example.com",
+		To:       []string{"a@example.com"},
+		Security: SecurityNone,
+	})
+	done := make(chan error, 1)
+	go func() { done <- n.SendError(monitor.AutoResult{Name: "Nopa"}, "boom") }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("sent to a server that never answered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting on a stalled server")
+	}
+}
@@ -0,0 +1,148 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"opentable-monitor/monitor"
+)
+
+// EventType names what happened to a watch.
+type EventType string
+
+const (
+	EventStarted      EventType = "monitoring_started"
+	EventSlotFound    EventType = "slot_found"
+	EventAlternatives EventType = "alternative_times"
+	EventBooked       EventType = "reservation_booked"
+	EventUpgrade      EventType = "upgrade_available"
+	EventStopped      EventType = "monitoring_stopped"
+	EventDegraded     EventType = "watch_degraded"
+	EventRecovered    EventType = "watch_recovered"
+	EventError        EventType = "error"
+)
+
+// Event is one monitor occurrence handed to a Notifier. Only the fields
+// relevant to Type are set.
+type Event struct {
+	Type         EventType
+	WatchID      string
+	Restaurant   monitor.AutoResult
+	Date         string
+	Time         string // preferred time; the open time for EventSlotFound
+	PartySize    int
+	URL          string // booking link
+	Alternatives []string
+	Slots        []monitor.Slot
+	Reason       string           // stop reason, error or health detail, or why auto-book failed
+	Booking      *monitor.Booking // EventBooked; on EventUpgrade, the moved booking
+	Current      string           // EventUpgrade only: time of the booking held
+}
+
+// Notifier delivers monitor events to one backend (Discord, Slack, …).
+type Notifier interface {
+	Notify(ev Event) error
+}
+
+// Multi fans every event out to several notifiers at once and joins
+// their errors, so one broken backend never blocks the others.
+type Multi []Notifier
+
+// Notify sends ev to every notifier concurrently.
+func (m Multi) Notify(ev Event) error {
+	errs := make([]error, len(m))
+	var wg sync.WaitGroup
+	for i, n := range m {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.Notify(ev); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", name(n), err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Flusher is implemented by notifiers that deliver in the background.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Flush waits for n's background deliveries, if it has any, to finish
+// or for ctx to end. Call it once before exiting.
+func Flush(ctx context.Context, n Notifier) error {
+	f, ok := n.(Flusher)
+	if !ok {
+		return nil
+	}
+	return f.Flush(ctx)
+}
+
+// Flush flushes every notifier that delivers in the background.
+func (m Multi) Flush(ctx context.Context) error {
+	var errs []error
+	for _, n := range m {
+		if err := Flush(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name(n), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// name labels a notifier in aggregated errors.
+func name(n Notifier) string {
+	if s, ok := n.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+// FromWatchEvent converts what a monitor watch reported into an Event
+// for restaurant.
+func FromWatchEvent(ev monitor.WatchEvent, restaurant monitor.AutoResult) Event {
+	out := Event{
+		WatchID:      ev.Watch.ID,
+		Restaurant:   restaurant,
+		Date:         ev.Watch.Date,
+		Time:         ev.Watch.TimePref,
+		PartySize:    ev.Watch.PartySize,
+		URL:          ev.URL,
+		Alternatives: ev.Alternatives,
+		Slots:        ev.Slots,
+	}
+	switch {
+	case ev.Err != nil:
+		out.Type, out.Reason = EventError, ev.Err.Error()
+	case ev.Health != nil:
+		out.Type, out.Reason = EventRecovered, ev.Health.String()
+		if ev.Health.Degraded {
+			out.Type = EventDegraded
+		}
+	case ev.Exact && ev.Watch.Upgrade != nil:
+		out.Type, out.Current, out.Booking = EventUpgrade, ev.Watch.Upgrade.Current, ev.Booking
+		out.Time, out.PartySize = ev.Time, ev.PartySize
+		if ev.BookErr != nil {
+			out.Reason = ev.BookErr.Error()
+		}
+	case ev.Exact:
+		out.Type = EventSlotFound
+		if ev.Booking != nil {
+			out.Type, out.Booking = EventBooked, ev.Booking
+		} else if ev.BookErr != nil {
+			out.Reason = ev.BookErr.Error()
+		}
+		if ev.Time != "" {
+			out.Time = ev.Time
+		}
+		if ev.PartySize > 0 {
+			out.PartySize = ev.PartySize
+		}
+	default:
+		out.Type = EventAlternatives
+	}
+	return out
+}
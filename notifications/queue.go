@@ -0,0 +1,392 @@
+package notifications
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	mrand "math/rand/v2"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"opentable-monitor/monitor"
+)
+
+// Retry tuning for Discord deliveries
+const (
+	maxInlineAttempts = 5                // SendWebhook without a queue
+	maxInlineWait     = 5 * time.Second  // all of SendWebhook's attempts without a queue
+	maxQueueAttempts  = 12               // per queued delivery
+	baseBackoff       = 1 * time.Second  // first retry after a 5xx / network error
+	maxBackoff        = 5 * time.Minute  // backoff ceiling
+	maxRetryAfter     = 10 * time.Minute // ignore absurd Retry-After values
+)
+
+// keepFinished is how many delivered or failed messages a DeliveryQueue
+// remembers for Status after they leave the queue.
+const keepFinished = 100
+
+// DeliveryStatus is where a queued Discord message stands.
+type DeliveryStatus string
+
+const (
+	DeliveryQueued    DeliveryStatus = "queued"
+	DeliveryRetrying  DeliveryStatus = "retrying"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is one message in a DeliveryQueue.
+type Delivery struct {
+	ID          string         `json:"id"`
+	Webhook     DiscordWebhook `json:"webhook"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	LastError   string         `json:"lastError,omitempty"`
+	NextAttempt time.Time      `json:"nextAttempt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	DeliveredAt time.Time      `json:"deliveredAt,omitempty"`
+}
+
+// sendError is a failed webhook POST, classified for the retry logic.
+type sendError struct {
+	status     int           // 0 = network error
+	retryAfter time.Duration // server-requested wait (429)
+	msg        string
+}
+
+func (e *sendError) Error() string { return e.msg }
+
+// retryable reports whether trying again could succeed.
+func (e *sendError) retryable() bool {
+	return e.status == 0 || e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+// rateLimiter tracks Discord's bucket so we pause before hitting a 429.
+type rateLimiter struct {
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+// wait blocks until the bucket allows another request or ctx ends.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	d := time.Until(l.resumeAt)
+	l.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// pause pushes the next allowed request at least d into the future.
+func (l *rateLimiter) pause(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	l.mu.Lock()
+	if at := time.Now().Add(d); at.After(l.resumeAt) {
+		l.resumeAt = at
+	}
+	l.mu.Unlock()
+}
+
+// observe reads the X-RateLimit-* headers of any response and, when the
+// bucket is exhausted, waits out its reset window before the next send.
+func (l *rateLimiter) observe(h http.Header) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	if secs, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		l.pause(time.Duration(secs * float64(time.Second)))
+	}
+}
+
+// retryAfter extracts how long Discord asked us to wait on a 429: the
+// Retry-After header, or "retry_after" in the JSON body.
+func retryAfter(h http.Header, body []byte) time.Duration {
+	if secs, err := strconv.ParseFloat(h.Get("Retry-After"), 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+	var v struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &v) == nil && v.RetryAfter > 0 {
+		return time.Duration(v.RetryAfter * float64(time.Second))
+	}
+	return time.Second
+}
+
+// backoff returns the wait before retry number attempt (1-based), with
+// full jitter so several notifiers don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	d := time.Duration(float64(baseBackoff) * math.Pow(2, float64(attempt-1)))
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(mrand.Int64N(int64(d/2)+1))
+}
+
+// nextWait is how long to hold off after a failed attempt.
+func nextWait(err *sendError, attempt int) time.Duration {
+	if err.retryAfter > 0 {
+		return min(err.retryAfter, maxRetryAfter)
+	}
+	return backoff(attempt)
+}
+
+// DeliveryQueue delivers Discord messages in the background, retrying
+// 429s and transient failures and persisting undelivered messages to a
+// JSON file so they survive a restart.
+type DeliveryQueue struct {
+	d    *DiscordNotifier
+	path string // "" = memory only
+
+	mu      sync.Mutex
+	items   map[string]*Delivery
+	done    []string // IDs of finished items, oldest first, at most keepFinished
+	wake    chan struct{}
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// newDeliveryQueue loads pending messages from path and starts the worker.
+func newDeliveryQueue(d *DiscordNotifier, path string) (*DeliveryQueue, error) {
+	q := &DeliveryQueue{
+		d:       d,
+		path:    path,
+		items:   map[string]*Delivery{},
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("read queue: %w", err)
+		case len(raw) > 0:
+			var list []*Delivery
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("decode queue %s: %w", path, err)
+			}
+			for _, dl := range list {
+				q.items[dl.ID] = dl
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.stop = cancel
+	go q.run(ctx)
+	return q, nil
+}
+
+// Enqueue adds a message and returns its delivery ID.
+func (q *DeliveryQueue) Enqueue(w DiscordWebhook) (string, error) {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	dl := &Delivery{
+		ID:          hex.EncodeToString(b[:]),
+		Webhook:     w,
+		Status:      DeliveryQueued,
+		NextAttempt: time.Now(),
+		CreatedAt:   time.Now(),
+	}
+
+	q.mu.Lock()
+	q.items[dl.ID] = dl
+	err := q.persist()
+	q.mu.Unlock()
+
+	q.nudge()
+	return dl.ID, err
+}
+
+// Status returns a snapshot of the delivery with the given ID. Finished
+// messages are remembered until keepFinished newer ones have finished.
+func (q *DeliveryQueue) Status(id string) (Delivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dl, ok := q.items[id]
+	if !ok {
+		return Delivery{}, false
+	}
+	return *dl, true
+}
+
+// Deliveries returns a snapshot of every pending delivery and the
+// recently finished ones, oldest first.
+func (q *DeliveryQueue) Deliveries() []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Delivery, 0, len(q.items))
+	for _, dl := range q.items {
+		out = append(out, *dl)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Flush waits until nothing is pending or ctx ends, then stops the
+// worker. Undelivered messages stay in the queue file for next time.
+// Calling it again after that only reports what is left.
+func (q *DeliveryQueue) Flush(ctx context.Context) error {
+	defer func() {
+		q.stop()
+		<-q.stopped
+	}()
+	for {
+		n := q.pending()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-q.stopped:
+			return fmt.Errorf("%d discord message(s) still queued", n)
+		case <-ctx.Done():
+			return fmt.Errorf("%d discord message(s) still queued: %w", q.pending(), ctx.Err())
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+func (q *DeliveryQueue) nudge() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *DeliveryQueue) pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, dl := range q.items {
+		if dl.Status == DeliveryQueued || dl.Status == DeliveryRetrying {
+			n++
+		}
+	}
+	return n
+}
+
+// next returns the oldest delivery that is due, or how long until one is.
+func (q *DeliveryQueue) next(now time.Time) (*Delivery, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due *Delivery
+	wait := time.Hour
+	for _, dl := range q.items {
+		if dl.Status != DeliveryQueued && dl.Status != DeliveryRetrying {
+			continue
+		}
+		if !dl.NextAttempt.After(now) {
+			if due == nil || dl.CreatedAt.Before(due.CreatedAt) {
+				due = dl
+			}
+		} else if d := dl.NextAttempt.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return due, wait
+}
+
+// run is the single worker; one at a time keeps Discord ordering intact.
+func (q *DeliveryQueue) run(ctx context.Context) {
+	defer close(q.stopped)
+	for {
+		dl, wait := q.next(time.Now())
+		if dl == nil {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-q.wake:
+			case <-t.C:
+			}
+			t.Stop()
+			continue
+		}
+
+		q.mu.Lock()
+		webhook := dl.Webhook
+		q.mu.Unlock()
+
+		err := q.d.attempt(ctx, webhook)
+		if ctx.Err() != nil {
+			return
+		}
+
+		q.mu.Lock()
+		dl.Attempts++
+		var se *sendError
+		switch {
+		case err == nil:
+			dl.Status, dl.LastError, dl.DeliveredAt = DeliveryDelivered, "", time.Now()
+			q.finish(dl)
+		case errors.As(err, &se) && se.retryable() && dl.Attempts < maxQueueAttempts:
+			dl.Status, dl.LastError = DeliveryRetrying, err.Error()
+			dl.NextAttempt = time.Now().Add(nextWait(se, dl.Attempts))
+		default:
+			dl.Status, dl.LastError = DeliveryFailed, err.Error()
+			q.finish(dl)
+		}
+		if perr := q.persist(); perr != nil {
+			dl.LastError = strings.TrimSpace(dl.LastError + "; " + perr.Error())
+		}
+		q.mu.Unlock()
+	}
+}
+
+// finish records that dl left the queue, forgetting the oldest finished
+// delivery once more than keepFinished are kept. Caller holds q.mu.
+func (q *DeliveryQueue) finish(dl *Delivery) {
+	q.done = append(q.done, dl.ID)
+	if len(q.done) > keepFinished {
+		delete(q.items, q.done[0])
+		q.done = q.done[1:]
+	}
+}
+
+// persist writes the still-pending deliveries to disk. Caller holds q.mu.
+func (q *DeliveryQueue) persist() error {
+	if q.path == "" {
+		return nil
+	}
+	list := make([]*Delivery, 0, len(q.items))
+	for _, dl := range q.items {
+		if dl.Status == DeliveryQueued || dl.Status == DeliveryRetrying {
+			list = append(list, dl)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode queue: %w", err)
+	}
+	if err := monitor.WriteFileAtomic(q.path, raw, 0o755); err != nil {
+		return fmt.Errorf("write queue: %w", err)
+	}
+	return nil
+}
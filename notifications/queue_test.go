@@ -0,0 +1,62 @@
+package notifications
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueueRemembersFinished(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("bad") != "" {
+			http.Error(w, "bad embed", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	q, err := NewDiscordNotifier(srv.URL).EnableQueue("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, _ := q.Enqueue(DiscordWebhook{Content: "hi"})
+	bad := NewDiscordNotifier(srv.URL + "?bad=1")
+	badQ, err := bad.EnableQueue("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, _ := badQ.Enqueue(DiscordWebhook{Content: "hi"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := badQ.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if dl, found := q.Status(ok); !found || dl.Status != DeliveryDelivered || dl.DeliveredAt.IsZero() {
+		t.Errorf("Status(delivered) = %+v, %v", dl, found)
+	}
+	if dl, found := badQ.Status(failed); !found || dl.Status != DeliveryFailed || dl.LastError == "" {
+		t.Errorf("Status(failed) = %+v, %v", dl, found)
+	}
+}
+
+func TestQueueForgetsOldest(t *testing.T) {
+	q := &DeliveryQueue{items: map[string]*Delivery{}}
+	for i := range keepFinished + 1 {
+		dl := &Delivery{ID: string(rune('a' + i)), Status: DeliveryDelivered}
+		q.items[dl.ID] = dl
+		q.finish(dl)
+	}
+	if _, found := q.Status("a"); found {
+		t.Error("oldest finished delivery still kept")
+	}
+	if n := len(q.items); n != keepFinished {
+		t.Errorf("kept %d, want %d", n, keepFinished)
+	}
+}
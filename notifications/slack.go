@@ -0,0 +1,379 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"opentable-monitor/monitor"
+)
+
+// Slack Block Kit structures (only what we render)
+type SlackMessage struct {
+	Text   string       `json:"text"` // fallback for notifications / old clients
+	Blocks []SlackBlock `json:"blocks,omitempty"`
+}
+
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Fields   []SlackText `json:"fields,omitempty"`
+	Elements []any       `json:"elements,omitempty"` // SlackButton or SlackText
+}
+
+type SlackText struct {
+	Type  string `json:"type"` // "plain_text" or "mrkdwn"
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type SlackButton struct {
+	Type  string    `json:"type"` // always "button"
+	Text  SlackText `json:"text"`
+	URL   string    `json:"url,omitempty"`
+	Style string    `json:"style,omitempty"` // "primary" / "danger"
+}
+
+// SlackNotifier posts Block Kit messages to a Slack incoming webhook.
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+// NewSlackNotifier creates a new Slack notifier with the given webhook URL
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// String names the backend in aggregated errors.
+func (s *SlackNotifier) String() string { return "slack" }
+
+// Notify implements Notifier by rendering ev as the matching message.
+func (s *SlackNotifier) Notify(ev Event) error {
+	switch ev.Type {
+	case EventStarted:
+		return s.SendMonitoringStarted(ev.Restaurant, ev.Date, ev.Time, ev.PartySize)
+	case EventSlotFound:
+		return s.sendSlotFound(ev.Restaurant, ev.Date, ev.Time, ev.PartySize, ev.URL, ev.Reason)
+	case EventBooked:
+		return s.SendBooked(ev.Restaurant, ev.Booking, ev.URL)
+	case EventUpgrade:
+		return s.SendUpgrade(ev.Restaurant, ev.Date, ev.Current, ev.Time, ev.PartySize, ev.URL, ev.Booking)
+	case EventAlternatives:
+		return s.SendAlternativeTimes(ev.Restaurant, ev.Date, ev.PartySize, ev.Alternatives, ev.URL)
+	case EventStopped:
+		return s.SendMonitoringStopped(ev.Restaurant, ev.Reason)
+	case EventDegraded:
+		return s.SendDegraded(ev.Restaurant, ev.Reason)
+	case EventRecovered:
+		return s.SendRecovered(ev.Restaurant, ev.Reason)
+	case EventError:
+		return s.SendError(ev.Restaurant, ev.Reason)
+	default:
+		return fmt.Errorf("unknown event type %q", ev.Type)
+	}
+}
+
+// SendMessage posts a message to the Slack webhook
+func (s *SlackNotifier) SendMessage(msg SlackMessage) error {
+	if s.webhookURL == "" {
+		return fmt.Errorf("slack webhook URL not configured")
+	}
+
+	jsonData, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %v", err)
+	}
+
+	resp, err := s.client.Post(s.webhookURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send slack message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Slack explains rejections in a short plain-text body
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack request failed with status %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// SendSlotFound sends a notification when the exact preferred slot is found
+func (s *SlackNotifier) SendSlotFound(restaurant monitor.AutoResult, date, timeSlot string, partySize int, reservationURL string) error {
+	return s.sendSlotFound(restaurant, date, timeSlot, partySize, reservationURL, "")
+}
+
+// sendSlotFound is SendSlotFound, also telling why auto-book failed when
+// bookErr is set.
+func (s *SlackNotifier) sendSlotFound(restaurant monitor.AutoResult, date, timeSlot string, partySize int, reservationURL, bookErr string) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("🎉 Reservation available at %s — %s %s, party of %d",
+			restaurant.Name, date, timeSlot, partySize),
+		Blocks: []SlackBlock{
+			slackHeader("✅ Exact Time Slot Found!"),
+			slackSection(fmt.Sprintf("Your preferred reservation slot is now available at *%s*!", slackEscape(restaurant.Name))),
+			slackFields(restaurant,
+				"*📅 Date*\n"+date,
+				"*⏰ Time*\n"+timeSlot,
+				fmt.Sprintf("*👥 Party Size*\n%d", partySize),
+			),
+			slackButton("Book Now", reservationURL, "primary"),
+			slackFooter("Book quickly before it's taken!"),
+		},
+	}
+	if bookErr != "" {
+		failed := slackSection("*⚠️ Auto-book failed*\n" + slackEscape(bookErr) + "\nBook it yourself with the button below.")
+		msg.Blocks = slices.Insert(msg.Blocks, 3, failed)
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendBooked sends a notification when auto-book reserved a slot
+func (s *SlackNotifier) SendBooked(restaurant monitor.AutoResult, b *monitor.Booking, reservationURL string) error {
+	header, intro := "📗 Reservation Booked!", fmt.Sprintf("A table at *%s* was reserved for you.", slackEscape(restaurant.Name))
+	confirmation := b.ConfirmationNumber
+	if b.DryRun {
+		header, intro = "🧪 Would Book (dry run)", fmt.Sprintf("Auto-book would have reserved this table at *%s*.", slackEscape(restaurant.Name))
+		confirmation = "—"
+	}
+	msg := SlackMessage{
+		Text: fmt.Sprintf("📗 Booked %s — %s %s, party of %d (confirmation %s)",
+			restaurant.Name, b.Date, b.Time, b.PartySize, confirmation),
+		Blocks: []SlackBlock{
+			slackHeader(header),
+			slackSection(intro),
+			slackFields(restaurant,
+				"*📅 Date*\n"+b.Date,
+				"*⏰ Time*\n"+b.Time,
+				fmt.Sprintf("*👥 Party Size*\n%d", b.PartySize),
+				"*🎫 Confirmation*\n"+confirmation,
+			),
+			slackButton("View on OpenTable", reservationURL, ""),
+			slackFooter("Check your email for OpenTable's confirmation"),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendUpgrade sends a notification when a slot better than the booking
+// held opens up, or when the booking was moved to it (moved != nil)
+func (s *SlackNotifier) SendUpgrade(restaurant monitor.AutoResult, date, current, candidate string, partySize int, reservationURL string, moved *monitor.Booking) error {
+	header := "⬆️ Upgrade Your Booking"
+	intro := fmt.Sprintf("A slot closer to your ideal time opened at *%s*.", slackEscape(restaurant.Name))
+	action := slackButton("Change Reservation", reservationURL, "primary")
+	switch {
+	case moved != nil && moved.DryRun:
+		header = "🧪 Would Move Booking (dry run)"
+		action = slackSection("Auto-modify would have moved your booking here.")
+	case moved != nil:
+		header = "✏️ Booking Upgraded"
+		intro = fmt.Sprintf("Your booking at *%s* was moved to the better time (confirmation #%s).",
+			slackEscape(restaurant.Name), slackEscape(moved.ConfirmationNumber))
+		action = slackButton("View on OpenTable", reservationURL, "")
+	}
+	msg := SlackMessage{
+		Text: fmt.Sprintf("⬆️ %s on %s: %s instead of %s, party of %d",
+			restaurant.Name, date, candidate, current, partySize),
+		Blocks: []SlackBlock{
+			slackHeader(header),
+			slackSection(intro),
+			slackFields(restaurant,
+				"*📅 Date*\n"+date,
+				fmt.Sprintf("*👥 Party Size*\n%d", partySize),
+				"*📌 Current*\n"+current,
+				"*✨ Candidate*\n"+candidate,
+			),
+			action,
+			slackFooter("Upgrade mode"),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendAlternativeTimes sends a notification when alternative times are available
+func (s *SlackNotifier) SendAlternativeTimes(restaurant monitor.AutoResult, date string, partySize int, alternativeTimes []string, reservationURL string) error {
+	lines := make([]string, len(alternativeTimes))
+	for i, t := range alternativeTimes {
+		lines[i] = slackLinks(t)
+	}
+	timesText := strings.Join(lines, "\n")
+	if len(timesText) > 2900 { // Slack section text limit is 3000
+		timesText = timesText[:2897] + "..."
+	}
+
+	blocks := []SlackBlock{
+		slackHeader("🔄 Alternative Reservation Times"),
+		slackSection(fmt.Sprintf("Your exact preferred time isn't available, but there are other options at *%s*!", slackEscape(restaurant.Name))),
+		slackFields(restaurant,
+			"*📅 Date*\n"+date,
+			fmt.Sprintf("*👥 Party Size*\n%d", partySize),
+		),
+		slackSection("*⏰ Available Times*\n" + timesText),
+	}
+	if reservationURL != "" {
+		blocks = append(blocks, slackButton("Book First Slot", reservationURL, ""))
+	}
+	blocks = append(blocks, slackFooter("Consider booking one of these times!"))
+
+	msg := SlackMessage{
+		Text: fmt.Sprintf("⏰ %d alternative time(s) at %s on %s",
+			len(alternativeTimes), restaurant.Name, date),
+		Blocks: blocks,
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendMonitoringStarted sends a notification when monitoring begins
+func (s *SlackNotifier) SendMonitoringStarted(restaurant monitor.AutoResult, date, preferredTime string, partySize int) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("🔍 Monitoring started for %s", restaurant.Name),
+		Blocks: []SlackBlock{
+			slackHeader("🎯 OpenTable Reservation Monitor Active"),
+			slackSection(fmt.Sprintf("Now monitoring *%s* for available reservations!", slackEscape(restaurant.Name))),
+			slackFields(restaurant,
+				"*📅 Date*\n"+date,
+				"*⏰ Preferred Time*\n"+preferredTime,
+				fmt.Sprintf("*👥 Party Size*\n%d", partySize),
+			),
+			slackFooter("You'll be notified when slots become available!"),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendMonitoringStopped sends a notification when monitoring stops
+func (s *SlackNotifier) SendMonitoringStopped(restaurant monitor.AutoResult, reason string) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("⏹️ Monitoring stopped for %s", restaurant.Name),
+		Blocks: []SlackBlock{
+			slackHeader("🛑 OpenTable Monitor Stopped"),
+			slackSection(fmt.Sprintf("Monitoring for *%s* has been stopped.", slackEscape(restaurant.Name))),
+			slackFields(restaurant, "*❓ Reason*\n"+slackEscape(reason)),
+			slackFooter(""),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendError sends an error notification
+func (s *SlackNotifier) SendError(restaurant monitor.AutoResult, errorMsg string) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("❌ Monitor error for %s: %s", restaurant.Name, errorMsg),
+		Blocks: []SlackBlock{
+			slackHeader("⚠️ OpenTable Monitor Error"),
+			slackSection(fmt.Sprintf("An error occurred while monitoring *%s*.", slackEscape(restaurant.Name))),
+			slackFields(restaurant),
+			slackSection("*❌ Error*\n```" + slackEscape(errorMsg) + "```"),
+			slackFooter("Please check the application"),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendDegraded warns that a watch keeps failing to poll
+func (s *SlackNotifier) SendDegraded(restaurant monitor.AutoResult, detail string) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("🩺 Monitor degraded for %s: %s", restaurant.Name, detail),
+		Blocks: []SlackBlock{
+			slackHeader("⚠️ OpenTable Monitor Degraded"),
+			slackSection(fmt.Sprintf("Checks for *%s* keep failing. The monitor is still retrying.", slackEscape(restaurant.Name))),
+			slackFields(restaurant, "*🩺 Details*\n"+slackEscape(detail)),
+			slackFooter("You'll hear again once it recovers"),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+// SendRecovered reports that a degraded watch is polling normally again
+func (s *SlackNotifier) SendRecovered(restaurant monitor.AutoResult, detail string) error {
+	msg := SlackMessage{
+		Text: fmt.Sprintf("✅ Monitor recovered for %s", restaurant.Name),
+		Blocks: []SlackBlock{
+			slackHeader("💚 OpenTable Monitor Recovered"),
+			slackSection(fmt.Sprintf("Checks for *%s* are working again.", slackEscape(restaurant.Name))),
+			slackFields(restaurant, "*🩺 Details*\n"+slackEscape(detail)),
+			slackFooter(""),
+		},
+	}
+
+	return s.SendMessage(msg)
+}
+
+//  Block helpers
+
+func slackHeader(text string) SlackBlock {
+	if len(text) > 150 { // header limit
+		text = text[:147] + "..."
+	}
+	return SlackBlock{Type: "header", Text: &SlackText{Type: "plain_text", Text: text, Emoji: true}}
+}
+
+func slackSection(markdown string) SlackBlock {
+	return SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: markdown}}
+}
+
+// slackFields renders the restaurant/location pair followed by extra
+// "*Label*\nvalue" fields (Slack allows up to 10 per section).
+func slackFields(restaurant monitor.AutoResult, extra ...string) SlackBlock {
+	fields := []SlackText{
+		{Type: "mrkdwn", Text: "*🏪 Restaurant*\n" + slackEscape(restaurant.Name)},
+		{Type: "mrkdwn", Text: "*📍 Location*\n" + slackEscape(fmt.Sprintf("%s, %s", restaurant.Neighborhood, restaurant.Metro))},
+	}
+	for _, f := range extra {
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: f})
+	}
+	return SlackBlock{Type: "section", Fields: fields}
+}
+
+// slackButton is an actions block holding one link button.
+func slackButton(label, url, style string) SlackBlock {
+	return SlackBlock{Type: "actions", Elements: []any{SlackButton{
+		Type:  "button",
+		Text:  SlackText{Type: "plain_text", Text: label, Emoji: true},
+		URL:   url,
+		Style: style,
+	}}}
+}
+
+func slackFooter(text string) SlackBlock {
+	footer := "OpenTable Monitor"
+	if text != "" {
+		footer += " • " + text
+	}
+	footer += " • " + time.Now().Format("2006-01-02 15:04 MST")
+	return SlackBlock{Type: "context", Elements: []any{SlackText{Type: "mrkdwn", Text: footer}}}
+}
+
+// slackEscape escapes the three characters Slack treats as control
+// sequences in mrkdwn.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// markdownLink matches the [label](url) links in alternative-time lines.
+var markdownLink = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+
+// slackLinks rewrites Discord-style [label](url) links to Slack's <url|label>.
+func slackLinks(s string) string {
+	return markdownLink.ReplaceAllString(s, "<$2|$1>")
+}
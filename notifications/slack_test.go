@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"opentable-monitor/monitor"
+)
+
+func TestSlackSlotFoundBlocks(t *testing.T) {
+	var got SlackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	restaurant := monitor.AutoResult{Name: "Fish & Chips <Bar>", Neighborhood: "SoHo", Metro: "New York"}
+	err := NewSlackNotifier(srv.URL).SendSlotFound(restaurant, "2026-11-02", "19:30", 2, "https://www.opentable.com/r/x")
+	if err != nil {
+		t.Fatalf("SendSlotFound: %v", err)
+	}
+
+	if !strings.Contains(got.Text, "Fish & Chips <Bar>") || !strings.Contains(got.Text, "party of 2") {
+		t.Errorf("fallback text = %q", got.Text)
+	}
+	var types []string
+	for _, b := range got.Blocks {
+		types = append(types, b.Type)
+	}
+	if want := "header section section actions context"; strings.Join(types, " ") != want {
+		t.Fatalf("block types = %v, want %s", types, want)
+	}
+	if h := got.Blocks[0].Text; h == nil || h.Type != "plain_text" {
+		t.Errorf("header text = %+v, want plain_text", h)
+	}
+	if s := got.Blocks[1].Text.Text; !strings.Contains(s, "Fish &amp; Chips &lt;Bar&gt;") {
+		t.Errorf("section not escaped: %q", s)
+	}
+	if n := len(got.Blocks[2].Fields); n != 5 {
+		t.Errorf("%d fields, want 5", n)
+	}
+	btn, _ := got.Blocks[3].Elements[0].(map[string]any)
+	if btn["type"] != "button" || btn["url"] != "https://www.opentable.com/r/x" || btn["style"] != "primary" {
+		t.Errorf("button = %v", btn)
+	}
+}
+
+func TestSlackSlotFoundBookFailed(t *testing.T) {
+	var got SlackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ev := Event{Type: EventSlotFound, Restaurant: monitor.AutoResult{Name: "Nopa"}, Date: "2026-11-02", Time: "19:30", PartySize: 2, Reason: "19:30: lock slot: slot taken"}
+	if err := NewSlackNotifier(srv.URL).Notify(ev); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(got.Blocks) < 4 || got.Blocks[3].Text == nil || !strings.Contains(got.Blocks[3].Text.Text, "slot taken") {
+		t.Errorf("blocks = %+v, want the auto-book failure after the fields", got.Blocks)
+	}
+}
+
+func TestSlackRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_blocks", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := NewSlackNotifier(srv.URL).SendError(monitor.AutoResult{Name: "Nopa"}, "boom")
+	if err == nil {
+		t.Fatal("SendError succeeded on a 400")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "400") || !strings.Contains(msg, "invalid_blocks") {
+		t.Errorf("error = %q, want the status and Slack's reason", msg)
+	}
+}
+
+func TestSlackNotConfigured(t *testing.T) {
+	if err := NewSlackNotifier("").SendError(monitor.AutoResult{}, "boom"); err == nil {
+		t.Error("sent without a webhook URL")
+	}
+}
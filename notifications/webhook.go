@@ -0,0 +1,228 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSchemaVersion is bumped on any breaking change to WebhookPayload.
+const WebhookSchemaVersion = 1
+
+// Headers sent with every outbound webhook.
+const (
+	HeaderEvent     = "X-OTM-Event"
+	HeaderID        = "X-OTM-Delivery"
+	HeaderTimestamp = "X-OTM-Timestamp" // unix seconds
+	HeaderSignature = "X-OTM-Signature" // "v1=" + hex HMAC-SHA256
+)
+
+// WebhookPayload is the stable JSON document POSTed for every event.
+type WebhookPayload struct {
+	Version    int               `json:"version"`
+	ID         string            `json:"id"`
+	Type       EventType         `json:"type"`
+	OccurredAt time.Time         `json:"occurredAt"`
+	WatchID    string            `json:"watchId,omitempty"`
+	Restaurant WebhookRestaurant `json:"restaurant"`
+	Date       string            `json:"date,omitempty"`
+	Time       string            `json:"time,omitempty"`
+	PartySize  int               `json:"partySize,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	Slots      []WebhookSlot     `json:"slots"`
+	Reason     string            `json:"reason,omitempty"`
+	Booking    *WebhookBooking   `json:"booking,omitempty"`
+	Current    string            `json:"current,omitempty"` // upgrade_available: time of the booking held
+}
+
+type WebhookRestaurant struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Neighborhood string `json:"neighborhood,omitempty"`
+	Metro        string `json:"metro,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+type WebhookSlot struct {
+	Date        string   `json:"date"`
+	Time        string   `json:"time"`
+	PartySize   int      `json:"partySize"`
+	Attributes  []string `json:"attributes"`
+	PointsType  string   `json:"pointsType,omitempty"`
+	PointsValue int      `json:"pointsValue,omitempty"`
+	IsMandatory bool     `json:"isMandatory"`
+	Experience  bool     `json:"experience"`
+	SlotHash    string   `json:"slotHash"`
+	URL         string   `json:"url"`
+}
+
+// WebhookBooking is the reservation auto-book made, on reservation_booked.
+type WebhookBooking struct {
+	ConfirmationNumber string `json:"confirmationNumber,omitempty"`
+	ReservationID      int64  `json:"reservationId,omitempty"`
+	Date               string `json:"date"`
+	Time               string `json:"time"`
+	PartySize          int    `json:"partySize"`
+	DryRun             bool   `json:"dryRun"`
+}
+
+// WebhookNotifier POSTs signed WebhookPayload documents to any endpoint.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier for url. Requests are signed
+// with secret; an empty secret sends them unsigned.
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// String names the backend in aggregated errors.
+func (w *WebhookNotifier) String() string { return "webhook" }
+
+// Notify implements Notifier.
+func (w *WebhookNotifier) Notify(ev Event) error {
+	return w.Send(NewWebhookPayload(ev))
+}
+
+// NewWebhookPayload converts ev into the versioned wire format.
+func NewWebhookPayload(ev Event) WebhookPayload {
+	p := WebhookPayload{
+		Version:    WebhookSchemaVersion,
+		ID:         newDeliveryID(),
+		Type:       ev.Type,
+		OccurredAt: time.Now().UTC(),
+		WatchID:    ev.WatchID,
+		Restaurant: WebhookRestaurant{
+			ID:           ev.Restaurant.ID,
+			Name:         ev.Restaurant.Name,
+			Neighborhood: ev.Restaurant.Neighborhood,
+			Metro:        ev.Restaurant.Metro,
+			Country:      ev.Restaurant.Country,
+		},
+		Date:      ev.Date,
+		Time:      ev.Time,
+		PartySize: ev.PartySize,
+		URL:       ev.URL,
+		Slots:     make([]WebhookSlot, 0, len(ev.Slots)),
+		Reason:    ev.Reason,
+		Current:   ev.Current,
+	}
+	if b := ev.Booking; b != nil {
+		p.Booking = &WebhookBooking{
+			ConfirmationNumber: b.ConfirmationNumber,
+			ReservationID:      b.ReservationID,
+			Date:               b.Date,
+			Time:               b.Time,
+			PartySize:          b.PartySize,
+			DryRun:             b.DryRun,
+		}
+	}
+	for _, s := range ev.Slots {
+		attrs := s.Attributes
+		if attrs == nil {
+			attrs = []string{}
+		}
+		p.Slots = append(p.Slots, WebhookSlot{
+			Date:        s.Date,
+			Time:        s.Time,
+			PartySize:   s.PartySize,
+			Attributes:  attrs,
+			PointsType:  s.PointsType,
+			PointsValue: s.PointsValue,
+			IsMandatory: s.IsMandatory,
+			Experience:  s.Experience,
+			SlotHash:    s.SlotHash,
+			URL:         s.URL,
+		})
+	}
+	return p
+}
+
+// Send signs and POSTs one payload.
+func (w *WebhookNotifier) Send(p WebhookPayload) error {
+	if w.url == "" {
+		return fmt.Errorf("webhook URL not configured")
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %v", err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "opentable-monitor-webhook/"+strconv.Itoa(WebhookSchemaVersion))
+	req.Header.Set(HeaderEvent, string(p.Type))
+	req.Header.Set(HeaderID, p.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	if w.secret != "" {
+		req.Header.Set(HeaderSignature, SignWebhook(w.secret, ts, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook request failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhook returns the signature header value for body sent at ts:
+// "v1=" + hex(HMAC-SHA256(secret, ts + "." + body)).
+func SignWebhook(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a received webhook: the signature must match and
+// the timestamp must be within tolerance of now, which stops replays.
+// Receivers written in Go can call it straight from their handler.
+func VerifyWebhook(secret, ts, signature string, body []byte, tolerance time.Duration) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp %q", ts)
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp outside tolerance (%s old)", age.Round(time.Second))
+	}
+	want := SignWebhook(secret, ts, body)
+	for _, sig := range strings.Split(signature, ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(sig)), []byte(want)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+// newDeliveryID returns a random hex ID receivers can use to de-duplicate.
+func newDeliveryID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
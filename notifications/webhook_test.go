@@ -0,0 +1,114 @@
+package notifications
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"opentable-monitor/monitor"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// HMAC-SHA256("shh", `1700000000.{"a":1}`), computed independently
+	want := "v1=be310eac0f84daf469d630347a950258de768b365563ab68f29f2f783473d547"
+	if got := SignWebhook("shh", "1700000000", []byte(`{"a":1}`)); got != want {
+		t.Errorf("SignWebhook = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookRoundTrip(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ev := Event{
+		Type:       EventSlotFound,
+		WatchID:    "nopa-friday",
+		Restaurant: monitor.AutoResult{ID: "1234", Name: "Nopa"},
+		Date:       "2026-11-02",
+		Time:       "19:30",
+		PartySize:  2,
+	}
+	if err := NewWebhookNotifier(srv.URL, "shh").Notify(ev); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var p WebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	for name, want := range map[string]string{
+		"Content-Type": "application/json",
+		HeaderEvent:    string(EventSlotFound),
+		HeaderID:       p.ID,
+	} {
+		if got := header.Get(name); got != want || want == "" {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	ts := header.Get(HeaderTimestamp)
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)) > time.Minute {
+		t.Errorf("%s = %q, want the current unix time", HeaderTimestamp, ts)
+	}
+	sig := header.Get(HeaderSignature)
+	if sig != SignWebhook("shh", ts, body) {
+		t.Errorf("%s = %q, want the body's signature", HeaderSignature, sig)
+	}
+
+	if err := VerifyWebhook("shh", ts, sig, body, 5*time.Minute); err != nil {
+		t.Errorf("VerifyWebhook: %v", err)
+	}
+	if err := VerifyWebhook("shh", ts, "v0=old, "+sig, body, 5*time.Minute); err != nil {
+		t.Errorf("VerifyWebhook with a rotated secret's signature listed first: %v", err)
+	}
+	if err := VerifyWebhook("other", ts, sig, body, 5*time.Minute); err == nil {
+		t.Error("verified with the wrong secret")
+	}
+	tampered := []byte(strings.Replace(string(body), `"19:30"`, `"20:30"`, 1))
+	if string(tampered) == string(body) {
+		t.Fatal("payload has no 19:30 to tamper with")
+	}
+	if err := VerifyWebhook("shh", ts, sig, tampered, 5*time.Minute); err == nil {
+		t.Error("verified a tampered body")
+	}
+}
+
+func TestVerifyWebhookTolerance(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	for _, age := range []time.Duration{10 * time.Minute, -10 * time.Minute} {
+		ts := strconv.FormatInt(time.Now().Add(-age).Unix(), 10)
+		if err := VerifyWebhook("shh", ts, SignWebhook("shh", ts, body), body, 5*time.Minute); err == nil {
+			t.Errorf("accepted a signature %s old", age)
+		}
+	}
+	if err := VerifyWebhook("shh", "soon", "v1=00", body, 5*time.Minute); err == nil {
+		t.Error("accepted a malformed timestamp")
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookNotifier(srv.URL, "").Notify(Event{Type: EventError}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if sig := header.Get(HeaderSignature); sig != "" {
+		t.Errorf("signed without a secret: %s", sig)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"opentable-monitor/monitor"
+	"opentable-monitor/notifications"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/huh/spinner"
+)
+
+func themed(f *huh.Form) *huh.Form { return f.WithTheme(huh.ThemeCharm()) }
+
+//  Label helpers
+
+func trunc(s string, n int) string {
+	if len(s) > n {
+		return s[:n-1] + "…"
+	}
+	return s
+}
+
+func menuLabel(r monitor.AutoResult) string {
+	return fmt.Sprintf("%-40s | %-20s | %-16s | %-12s | %-10s | %s",
+		trunc(r.Name, 40),
+		trunc(r.Neighborhood, 20),
+		trunc(r.Metro, 16),
+		r.Country,
+		r.Type,
+		r.ID,
+	)
+}
+
+func confirmLabel(r monitor.AutoResult) string {
+	return strings.Join([]string{
+		r.Name, r.Neighborhood, r.Metro, r.Country, r.Type, r.ID,
+	}, " | ")
+}
+
+//  Time-slot builder
+
+func buildTimes() []string {
+	times := make([]string, 0, 48)
+	for h := range 24 {
+		for _, m := range []int{0, 30} {
+			times = append(times, fmt.Sprintf("%02d:%02d", h, m))
+		}
+	}
+	return times
+}
+
+//  Interactive loop
+
+// runTUI drives the huh forms: search, pick, queue one or more watches,
+// then run them all until each has finished.
+func runTUI(cli *monitor.Client, notifier notifications.Notifier) {
+	var watches []queuedWatch
+
+	for {
+		// search term
+		var term string
+		if err := themed(huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("🔍  Restaurant search (blank = quit)").
+					Placeholder("House of Prime Rib").
+					Value(&term),
+			))).Run(); err != nil || strings.TrimSpace(term) == "" {
+			fmt.Println("Bye!")
+			return
+		}
+
+		// fetch results
+		var results []monitor.AutoResult
+		var fetchErr error
+
+		// per-search deadline: queuing several watches easily outlives 30 s
+		searchCtx, searchCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_ = spinner.New().
+			Title("Fetching results…").
+			Context(searchCtx).
+			Action(func() {
+				results, fetchErr = cli.Autocomplete(searchCtx, term)
+			}).
+			Run()
+		searchCancel()
+
+		if fetchErr != nil {
+			log.Printf("autocomplete: %v\n", fetchErr)
+			continue
+		}
+		if len(results) == 0 {
+			fmt.Println("No matches – try again.")
+			continue
+		}
+
+		// restaurant select
+		resOpts := make([]huh.Option[string], 0, len(results)+1)
+		for _, r := range results {
+			resOpts = append(resOpts, huh.NewOption(menuLabel(r), r.ID))
+		}
+		resOpts = append(resOpts, huh.NewOption("🔄  New search", "redo"))
+
+		var pickedID string
+		if err := themed(huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title("Select restaurant (↑/↓, ⏎)").
+					Options(resOpts...).
+					Height(12).
+					Value(&pickedID),
+			))).Run(); err != nil {
+			fmt.Println("Selection aborted.")
+			return
+		}
+		if pickedID == "redo" {
+			continue
+		}
+
+		var picked monitor.AutoResult
+		for _, r := range results {
+			if r.ID == pickedID {
+				picked = r
+				break
+			}
+		}
+
+		// date select
+		var datePref string
+		if err := themed(huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("🗓️  Reservation date(s)").
+					Description("YYYY-MM-DD, a range like 2025-11-01..2025-11-15, or e.g. \"fri,sat next 6w\"").
+					Placeholder(time.Now().Format("2006-01-02")).
+					Validate(func(v string) error {
+						spec, err := monitor.ParseDateSpec(v)
+						if err != nil {
+							return err
+						}
+						if len(spec.Expand(time.Now())) == 0 {
+							return fmt.Errorf("no upcoming dates")
+						}
+						return nil
+					}).
+					Value(&datePref),
+			))).Run(); err != nil {
+			fmt.Println("Selection aborted.")
+			return
+		}
+
+		// time-slot select
+		timeOpts := buildTimes()
+		tsOpts := make([]huh.Option[string], len(timeOpts))
+		for i, t := range timeOpts {
+			tsOpts[i] = huh.NewOption(t, t)
+		}
+
+		var timePref string
+		if err := themed(huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title("⏰  Preferred reservation time (24-hour)").
+					Description("We'll ping you for the closest available slots.").
+					Options(tsOpts...).
+					Height(10).
+					Value(&timePref),
+			))).Run(); err != nil {
+			fmt.Println("Selection aborted.")
+			return
+		}
+
+		// party size
+		var partySizeStr string
+		var partySize int
+
+		// Use string for the form
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("👥  Party size").
+					Placeholder("2").
+					Validate(func(v string) error {
+						if _, err := strconv.Atoi(v); err != nil {
+							return fmt.Errorf("must be a valid number")
+						}
+						return nil
+					}).
+					Value(&partySizeStr), // Use string pointer here
+			),
+		)
+
+		// After form submission, convert to int
+		if err := form.Run(); err == nil {
+			partySize, _ = strconv.Atoi(partySizeStr)
+		}
+
+		// queue this watch, then offer to add another
+		datePref = strings.TrimSpace(datePref)
+		dates, _ := monitor.ParseDateSpec(datePref)
+		watches = append(watches, queuedWatch{
+			restaurant: picked,
+			date:       datePref,
+			dates:      dates,
+			timePref:   timePref,
+			partySize:  partySize,
+		})
+		fmt.Printf("\n➕  Queued: %s — %s %s, party %d\n",
+			confirmLabel(picked), datePref, timePref, partySize)
+
+		var more bool
+		if err := themed(huh.NewForm(
+			huh.NewGroup(
+				huh.NewConfirm().
+					Title("Add another restaurant or date?").
+					Affirmative("Yes").
+					Negative("No").
+					Value(&more),
+			))).Run(); err != nil {
+			fmt.Println("Aborted.")
+			return
+		}
+		if more {
+			continue
+		}
+
+		// start-monitor confirmation
+		var start bool
+		if err := themed(huh.NewForm(
+			huh.NewGroup(
+				huh.NewConfirm().
+					Title(fmt.Sprintf("Start monitoring %d watch(es)?", len(watches))).
+					Affirmative("Yes").
+					Negative("No").
+					Value(&start),
+			))).Run(); err != nil {
+			fmt.Println("Aborted.")
+			return
+		}
+		if !start {
+			fmt.Println("\nMonitor cancelled.")
+			return
+		}
+
+		runWatches(cli, notifier, watches)
+
+		// After every watch exits (slot found or ctx cancelled) we're done.
+		return
+	}
+}
+
+// queuedWatch is one restaurant/date/time/party picked in the TUI.
+type queuedWatch struct {
+	restaurant monitor.AutoResult
+	date       string           // as typed
+	dates      monitor.DateSpec // parsed from date
+	timePref   string
+	partySize  int
+}
+
+// runWatches registers every queued watch on a single WatchList, wires
+// each one to the notifier and blocks until they have all finished.
+func runWatches(cli *monitor.Client, notifier notifications.Notifier, watches []queuedWatch) {
+	wl := cli.NewWatchList()
+	byID := make(map[string]queuedWatch, len(watches))
+
+	for i, q := range watches {
+		id := fmt.Sprintf("%d-%s", i+1, q.restaurant.ID)
+		byID[id] = q
+
+		// final message
+		fmt.Printf("\n✅  Monitoring: %s\n", confirmLabel(q.restaurant))
+		fmt.Printf("   Preferred date : %s\n", q.date)
+		fmt.Printf("   Preferred time : %s\n", q.timePref)
+		fmt.Printf("   Party size     : %d\n", q.partySize)
+
+		// Send initial monitoring started notification
+		notify(notifier, notifications.Event{
+			Type:       notifications.EventStarted,
+			WatchID:    id,
+			Restaurant: q.restaurant,
+			Date:       q.date,
+			Time:       q.timePref,
+			PartySize:  q.partySize,
+		})
+
+		w := monitor.Watch{
+			ID:           id,
+			RestaurantID: q.restaurant.ID,
+			TimePref:     q.timePref,
+			PartySize:    q.partySize,
+			OnEvent: func(ev monitor.WatchEvent) {
+				// slot found, alternative times or error
+				notify(notifier, notifications.FromWatchEvent(ev, q.restaurant))
+			},
+		}
+		var err error
+		if q.dates.IsSingle() {
+			w.Date = q.dates.Dates[0]
+			err = wl.Add(w)
+		} else {
+			err = wl.AddRange(w, q.dates)
+		}
+		if err != nil {
+			log.Printf("watch %s: %v", q.restaurant.ID, err)
+		}
+	}
+	fmt.Printf("   Notifications configured: %s\n\n", "✅")
+
+	// Use a fresh, cancellable context so the monitor isn't limited to 30 s.
+	monitorCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	// Run the monitor inside a spinner for a nicer UX.
+	_ = spinner.New().
+		Title("Running reservation monitor… (Ctrl-C to quit)").
+		Context(monitorCtx).
+		Action(func() {
+			if err := wl.Run(monitorCtx); err != nil {
+				log.Printf("monitor: %v\n", err)
+			}
+		}).
+		Run()
+
+	// Send monitoring stopped notification
+	for _, st := range wl.Watches() {
+		notify(notifier, notifications.Event{
+			Type:       notifications.EventStopped,
+			WatchID:    st.Watch.ID,
+			Restaurant: byID[st.Watch.Origin()].restaurant,
+			Date:       st.Watch.Date,
+			Reason:     stopReason(st.Status),
+		})
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := notifications.Flush(flushCtx, notifier); err != nil {
+		log.Printf("Failed to deliver queued notifications: %v", err)
+	}
+}
+
+// stopReason explains a finished watch in its stop notification.
+func stopReason(s monitor.WatchStatus) string {
+	switch s {
+	case monitor.WatchFound:
+		return "Preferred slot found"
+	case monitor.WatchExpired:
+		return "Date has passed"
+	case monitor.WatchFailed:
+		return "Gave up after repeated errors"
+	default:
+		return "Monitor completed or cancelled"
+	}
+}
+
+// notify hands ev to n, which delivers in the background (see
+// envNotifier), and logs, rather than aborts on, a dropped event.
+func notify(n notifications.Notifier, ev notifications.Event) {
+	if err := n.Notify(ev); err != nil {
+		logNotifyErr(ev, err)
+	}
+}